@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/internal/auth"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const apiKeyUsage = `usage: quotation apikey <command> [flags]
+
+commands:
+  create -name NAME [-quota N] [-scopes S]   create a key and print it once
+  list                                        list keys
+  revoke ID                                   revoke a key
+  usage [-days N] ID                          show daily request counters of a key
+
+scopes: quotes:read, quotes:write, admin (comma separated)
+`
+
+// runAPIKey implements the "quotation apikey" admin commands and returns the
+// process exit code.
+func runAPIKey(ctx context.Context, a *auth.Auth, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, apiKeyUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = createAPIKey(ctx, a, args[1:])
+	case "list":
+		err = listAPIKeys(ctx, a)
+	case "revoke":
+		err = revokeAPIKey(ctx, a, args[1:])
+	case "usage":
+		err = showAPIKeyUsage(ctx, a, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, apiKeyUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func createAPIKey(ctx context.Context, a *auth.Auth, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "key name")
+	quota := fs.Int("quota", 0, "daily request quota, 0 for unlimited")
+	scopes := fs.String("scopes", models.ScopeQuotesRead, "comma separated scopes")
+	_ = fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if *quota < 0 {
+		return fmt.Errorf("-quota must not be negative")
+	}
+
+	k, plain, err := a.CreateKey(ctx, *name, *quota, strings.Split(*scopes, ","))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("id:     %s\nname:   %s\nquota:  %d\nscopes: %s\nkey:    %s\n",
+		k.ID, k.Name, k.DailyQuota, strings.Join(k.Scopes, ","), plain)
+	fmt.Println("store the key now, it cannot be shown again")
+	return nil
+}
+
+func listAPIKeys(ctx context.Context, a *auth.Auth) error {
+	keys, err := a.GetKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tQUOTA\tSCOPES\tCREATED\tREVOKED")
+	for _, k := range keys {
+		revoked := "-"
+		if k.RevokedAt != nil {
+			revoked = k.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			k.ID, k.Name, k.Prefix, k.DailyQuota, strings.Join(k.Scopes, ","), k.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	return tw.Flush()
+}
+
+func revokeAPIKey(ctx context.Context, a *auth.Auth, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: quotation apikey revoke ID")
+	}
+
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	return a.RevokeKey(ctx, id)
+}
+
+func showAPIKeyUsage(ctx context.Context, a *auth.Auth, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	days := fs.Int("days", 30, "number of days to show")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: quotation apikey usage [-days N] ID")
+	}
+	if *days <= 0 {
+		return fmt.Errorf("-days must be positive")
+	}
+
+	id, err := uuid.Parse(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid id: %w", err)
+	}
+
+	usage, err := a.GetUsage(ctx, id, *days)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DAY\tREQUESTS")
+	for _, u := range usage {
+		fmt.Fprintf(tw, "%s\t%d\n", u.Day.Format(time.DateOnly), u.Requests)
+	}
+	return tw.Flush()
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
 	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
 	cronSc "github.com/mashmorsik/quotation/cron"
 	"github.com/mashmorsik/quotation/infrastructure/data"
 	"github.com/mashmorsik/quotation/infrastructure/server"
+	"github.com/mashmorsik/quotation/internal/alert"
+	"github.com/mashmorsik/quotation/internal/auth"
 	"github.com/mashmorsik/quotation/internal/quotation"
+	"github.com/mashmorsik/quotation/internal/ratelimit"
+	"github.com/mashmorsik/quotation/internal/retention"
+	"github.com/mashmorsik/quotation/internal/webhook"
 	"github.com/mashmorsik/quotation/repository"
 	"os"
 	"os/signal"
@@ -33,24 +40,212 @@ func main() {
 		cancel()
 	}()
 
-	conn := data.MustConnectPostgres(ctx, conf)
-	data.MustMigrate(conn)
+	var (
+		dat       *data.Data
+		quoteRepo repository.Repository
+		// prepare waits for the database; the API answers 503 until it
+		// returns
+		prepare = func() error { return nil }
+		// migrator waits for the database and opens its migrations for the
+		// migrate subcommand
+		migrator func() (*migrate.Migrate, error)
+	)
+	switch conf.Storage.Driver {
+	case "", repository.DriverPostgres:
+		conn, err := data.OpenPostgres(ctx, conf)
+		if err != nil {
+			logger.Errf("Error configuring postgres: %v", err)
+			return
+		}
+		prepare = func() error {
+			if err := data.WaitForPostgres(ctx, conn, conf); err != nil {
+				return err
+			}
+			return data.MigratePostgres(ctx, conn, conf)
+		}
+		migrator = func() (*migrate.Migrate, error) {
+			if err := data.WaitForPostgres(ctx, conn, conf); err != nil {
+				return nil, err
+			}
+			return data.NewPostgresMigrate(conn)
+		}
 
-	dat := data.NewData(ctx, conn)
+		replicas, err := data.ConnectReplicas(ctx, conf)
+		if err != nil {
+			logger.Errf("Error configuring postgres replicas: %v", err)
+			return
+		}
+		dat = data.NewData(ctx, conn, replicas...)
+		quoteRepo = repository.NewQuoteRepo(dat, conf)
+	case repository.DriverSQLite:
+		logger.Info("Using SQLite storage, alerts, webhooks, retention and API keys are disabled")
+		conn, err := data.ConnectSQLite(ctx, conf)
+		if err != nil {
+			logger.Errf("Error opening sqlite: %v", err)
+			os.Exit(1)
+		}
+		prepare = func() error { return data.MigrateSQLite(conn) }
+		migrator = func() (*migrate.Migrate, error) { return data.NewSQLiteMigrate(conn) }
+
+		quoteRepo = repository.NewSQLiteRepo(data.NewData(ctx, conn), conf)
+	case repository.DriverMemory:
+		logger.Info("Using in-memory storage, alerts, webhooks, retention and API keys are disabled")
+		quoteRepo = repository.NewMemoryRepo()
+	default:
+		logger.Errf("Unknown storage driver: %q", conf.Storage.Driver)
+		return
+	}
+
+	var cachedRepo *repository.CachedRepo
+	if conf.Storage.Cache.Enabled {
+		cachedRepo = repository.NewCachedRepo(quoteRepo, conf.Storage.Cache.TTL, conf.Storage.Cache.Size)
+		quoteRepo = cachedRepo
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if migrator == nil {
+			logger.Errf("The %s storage driver has no migrations", conf.Storage.Driver)
+			os.Exit(1)
+		}
+		m, err := migrator()
+		if err != nil {
+			logger.Errf("Database is not reachable: %v", err)
+			os.Exit(1)
+		}
+		os.Exit(runMigrate(m, os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if dat == nil {
+			logger.Errf("API keys need the %s storage driver", repository.DriverPostgres)
+			os.Exit(1)
+		}
+		if err = prepare(); err != nil {
+			logger.Errf("Database is not reachable: %v", err)
+			os.Exit(1)
+		}
+		keys := auth.NewAuth(repository.NewAPIKeyRepo(dat, conf), conf)
+		os.Exit(runAPIKey(ctx, keys, os.Args[2:]))
+	}
+
+	if err = checkAuth(conf); err != nil {
+		logger.Errf("Refusing to start: %v", err)
+		os.Exit(1)
+	}
+	if err = checkWebhookSecret(conf); err != nil {
+		logger.Errf("Refusing to start: %v", err)
+		os.Exit(1)
+	}
 
-	quoteRepo := repository.NewQuoteRepo(ctx, dat)
 	qq := quotation.NewQuotation(ctx, quoteRepo, conf)
 
-	dt := cronSc.NewData(quoteRepo, conf)
-	_, err = dt.RunScheduler()
-	if err != nil {
-		logger.Errf("Error running scheduler: %v", err)
-		return
+	var (
+		alerts   *alert.Alert
+		webhooks *webhook.Webhook
+		keys     *auth.Auth
+	)
+	var background []func()
+	if dat != nil {
+		webhookRepo := repository.NewWebhookRepo(dat, conf)
+		webhooks = webhook.NewWebhook(ctx, webhookRepo, conf)
+		qq.Webhooks = webhooks
+
+		retentionRepo := repository.NewRetentionRepo(dat, conf)
+		ret := retention.NewRetention(ctx, retentionRepo, conf)
+		ret.Partitions = retentionRepo
+		ret.Webhooks = webhookRepo
+
+		background = append(background,
+			func() { dat.RunHealthCheck(conf.Postgres.ReplicaCheckInterval) },
+			webhooks.Run,
+			ret.Run,
+		)
+
+		if cachedRepo != nil {
+			// drop cached latest quotes as soon as any instance inserts
+			listener, err := data.NewListener(conf)
+			if err != nil {
+				logger.Errf("Error configuring the quotation listener: %v", err)
+				return
+			}
+			listener.Subscribe(cachedRepo.HandleQuote)
+			background = append(background, func() { listener.Run(ctx) })
+		}
+
+		alerts = alert.NewAlert(repository.NewAlertRepo(dat, conf), quoteRepo, conf)
+		alerts.Webhooks = webhooks
+
+		if conf.Auth.Enabled {
+			keys = auth.NewAuth(repository.NewAPIKeyRepo(dat, conf), conf)
+		}
 	}
-	logger.Info("Scheduler started")
 
 	httpServer := server.NewServer(conf, *qq)
+	httpServer.Alerts = alerts
+	httpServer.Webhooks = webhooks
+	httpServer.Auth = keys
+	httpServer.Cache = cachedRepo
+	if conf.RateLimit.Enabled {
+		httpServer.Limiter, err = ratelimit.NewLimiter(conf)
+		if err != nil {
+			logger.Errf("Error configuring rate limits: %v", err)
+			return
+		}
+	}
+
+	go func() {
+		if err := prepare(); err != nil {
+			logger.Errf("Database is not reachable, shutting down: %v", err)
+			cancel()
+			return
+		}
+
+		for _, run := range background {
+			go run()
+		}
+
+		dt := cronSc.NewData(ctx, quoteRepo, alerts, conf)
+		if _, err := dt.RunScheduler(); err != nil {
+			logger.Errf("Error running scheduler: %v", err)
+			cancel()
+			return
+		}
+		logger.Info("Scheduler started")
+
+		httpServer.SetReady(true)
+		logger.Info("Ready to serve requests")
+	}()
+
 	if err = httpServer.StartServer(ctx); err != nil {
 		logger.Warn(err.Error())
 	}
 }
+
+// placeholderWebhookSecret is the webhook secret config.yaml ships with.
+const placeholderWebhookSecret = "change-me"
+
+// checkWebhookSecret rejects an empty or placeholder webhook secret with
+// the drivers that send webhooks, as callbacks to ad-hoc URLs are signed
+// with it.
+func checkWebhookSecret(conf *config.Config) error {
+	if conf.Storage.Driver != "" && conf.Storage.Driver != repository.DriverPostgres {
+		return nil
+	}
+
+	switch conf.Webhook.Secret {
+	case "":
+		return fmt.Errorf("webhook.secret is empty")
+	case placeholderWebhookSecret:
+		return fmt.Errorf("webhook.secret is still %q", placeholderWebhookSecret)
+	}
+	return nil
+}
+
+// checkAuth rejects configs enabling authentication with a storage driver
+// that has no API key store, as the API would then be served open.
+func checkAuth(conf *config.Config) error {
+	if conf.Auth.Enabled && conf.Storage.Driver != "" && conf.Storage.Driver != repository.DriverPostgres {
+		return fmt.Errorf("auth is enabled, but the %s storage driver has no API key store", conf.Storage.Driver)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/repository"
+	"testing"
+)
+
+func Test_checkWebhookSecret(t *testing.T) {
+	tests := []struct {
+		driver  string
+		secret  string
+		wantErr bool
+	}{
+		{driver: "", secret: "", wantErr: true},
+		{driver: repository.DriverPostgres, secret: "change-me", wantErr: true},
+		{driver: repository.DriverPostgres, secret: "s3cr3t"},
+		{driver: repository.DriverSQLite, secret: ""},
+		{driver: repository.DriverMemory, secret: "change-me"},
+	}
+	for _, tt := range tests {
+		conf := &config.Config{}
+		conf.Storage.Driver = tt.driver
+		conf.Webhook.Secret = tt.secret
+
+		if err := checkWebhookSecret(conf); (err != nil) != tt.wantErr {
+			t.Errorf("checkWebhookSecret(driver=%q, secret=%q) error = %v, wantErr %v", tt.driver, tt.secret, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_checkAuth(t *testing.T) {
+	tests := []struct {
+		driver  string
+		auth    bool
+		wantErr bool
+	}{
+		{driver: "", auth: true},
+		{driver: repository.DriverPostgres, auth: true},
+		{driver: repository.DriverMemory, auth: false},
+		{driver: repository.DriverMemory, auth: true, wantErr: true},
+		{driver: repository.DriverSQLite, auth: false},
+		{driver: repository.DriverSQLite, auth: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		conf := &config.Config{}
+		conf.Storage.Driver = tt.driver
+		conf.Auth.Enabled = tt.auth
+
+		if err := checkAuth(conf); (err != nil) != tt.wantErr {
+			t.Errorf("checkAuth(driver=%q, auth=%v) error = %v, wantErr %v", tt.driver, tt.auth, err, tt.wantErr)
+		}
+	}
+}
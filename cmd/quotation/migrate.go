@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: quotation migrate <command>
+
+commands:
+  up [N]       apply all pending migrations, or the next N
+  down [N]     roll back the last migration, or the last N
+  version      print the current version and whether it is dirty
+  force V      set the version to V without running migrations, to recover
+               from a dirty state after a failed migration
+`
+
+// runMigrate implements the "quotation migrate" commands and returns the
+// process exit code.
+func runMigrate(m *migrate.Migrate, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "up":
+		err = migrateSteps(m, args[1:], 0, 1)
+	case "down":
+		err = migrateSteps(m, args[1:], 1, -1)
+	case "version":
+		err = showMigrateVersion(m)
+	case "force":
+		err = forceMigrateVersion(m, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no change")
+		err = nil
+	}
+	if err == nil && args[0] != "version" {
+		err = showMigrateVersion(m)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// migrateSteps moves N migrations in direction sign, or def steps without
+// an argument; 0 applies everything pending.
+func migrateSteps(m *migrate.Migrate, args []string, def, sign int) error {
+	n := def
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return fmt.Errorf("invalid number of steps: %q", args[0])
+		}
+	}
+
+	if n == 0 {
+		return m.Up()
+	}
+	return m.Steps(sign * n)
+}
+
+func showMigrateVersion(m *migrate.Migrate) error {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		fmt.Printf("version %d (dirty)\n", version)
+		return nil
+	}
+	fmt.Printf("version %d\n", version)
+	return nil
+}
+
+func forceMigrateVersion(m *migrate.Migrate, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: quotation migrate force V")
+	}
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version < -1 {
+		return fmt.Errorf("invalid version: %q", args[0])
+	}
+
+	return m.Force(version)
+}
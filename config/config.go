@@ -3,14 +3,55 @@ package config
 import (
 	errs "github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	Postgres struct {
-		Host string `yaml:"host"`
-		Port string `yaml:"port"`
+		// DSN, when set, is used as is instead of the fields below.
+		DSN      string `yaml:"dsn"`
+		Host     string `yaml:"host"`
+		Port     string `yaml:"port"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		// PasswordFile is read instead of Password when set, e.g. a Docker
+		// secret.
+		PasswordFile string `yaml:"passwordFile"`
+		Database     string `yaml:"database"`
+		// SSLMode is one of disable, require, verify-ca and verify-full.
+		SSLMode              string        `yaml:"sslMode"`
+		SSLRootCert          string        `yaml:"sslRootCert"`
+		SSLCert              string        `yaml:"sslCert"`
+		SSLKey               string        `yaml:"sslKey"`
+		ConnectTimeout       time.Duration `yaml:"connectTimeout"`
+		MaxOpenConns         int           `yaml:"maxOpenConns"`
+		MaxIdleConns         int           `yaml:"maxIdleConns"`
+		ConnMaxLifetime      time.Duration `yaml:"connMaxLifetime"`
+		ConnMaxIdleTime      time.Duration `yaml:"connMaxIdleTime"`
+		Replicas             []string      `yaml:"replicas"`
+		ReplicaCheckInterval time.Duration `yaml:"replicaCheckInterval"`
+		// RetryAttempts bounds the connect and migrate attempts at startup;
+		// the backoff between them doubles up to RetryMaxBackoff.
+		RetryAttempts   int           `yaml:"retryAttempts"`
+		RetryBackoff    time.Duration `yaml:"retryBackoff"`
+		RetryMaxBackoff time.Duration `yaml:"retryMaxBackoff"`
 	} `yaml:"postgres"`
+	Storage struct {
+		Driver   string                   `yaml:"driver"`
+		Timeout  time.Duration            `yaml:"timeout"`
+		Timeouts map[string]time.Duration `yaml:"timeouts"`
+		SQLite   struct {
+			Path string `yaml:"path"`
+		} `yaml:"sqlite"`
+		// Cache keeps the latest quote per pair and the pair list for TTL
+		// and quotes by ID until evicted, at most Size entries each.
+		Cache struct {
+			Enabled bool          `yaml:"enabled"`
+			TTL     time.Duration `yaml:"ttl"`
+			Size    int           `yaml:"size"`
+		} `yaml:"cache"`
+	} `yaml:"storage"`
 	Quotations []string `yaml:"quotations"`
 	Server     struct {
 		Port string `yaml:"port"`
@@ -19,10 +60,60 @@ type Config struct {
 		URL string `yaml:"url"`
 	} `yaml:"quoteApi"`
 	Cron struct {
-		Location string `yaml:"location"`
-		Period   string `yaml:"period"`
+		Location       string        `yaml:"location"`
+		Period         string        `yaml:"period"`
+		ReloadInterval time.Duration `yaml:"reloadInterval"`
 	} `yaml:"cron"`
 	ResponseDelay time.Duration `yaml:"responseDelay"`
+	Stats         struct {
+		CacheTTL time.Duration `yaml:"cacheTTL"`
+	} `yaml:"stats"`
+	Webhook struct {
+		Secret string `yaml:"secret"`
+		// AllowPrivateNetworks lets webhooks reach loopback, private and
+		// link-local addresses, e.g. for local development.
+		AllowPrivateNetworks bool          `yaml:"allowPrivateNetworks"`
+		PollInterval         time.Duration `yaml:"pollInterval"`
+		Timeout              time.Duration `yaml:"timeout"`
+		MaxAttempts          int           `yaml:"maxAttempts"`
+		BackoffBase          time.Duration `yaml:"backoffBase"`
+		BackoffMax           time.Duration `yaml:"backoffMax"`
+		DisableAfter         int           `yaml:"disableAfter"`
+		BatchSize            int           `yaml:"batchSize"`
+	} `yaml:"webhook"`
+	Retention struct {
+		Interval  time.Duration `yaml:"interval"`
+		Raw       time.Duration `yaml:"raw"`
+		Hourly    time.Duration `yaml:"hourly"`
+		Daily     time.Duration `yaml:"daily"`
+		BatchSize int           `yaml:"batchSize"`
+		// Webhooks is how long delivered and failed webhook messages and
+		// the delivery log are kept.
+		Webhooks time.Duration `yaml:"webhooks"`
+		// PartitionsAhead is how many months of quotation partitions are
+		// kept created beyond the current one.
+		PartitionsAhead int `yaml:"partitionsAhead"`
+		// DetachPartitions detaches expired partitions instead of dropping
+		// them, leaving a plain table to archive.
+		DetachPartitions bool `yaml:"detachPartitions"`
+	} `yaml:"retention"`
+	Auth struct {
+		Enabled     bool     `yaml:"enabled"`
+		PublicPaths []string `yaml:"publicPaths"`
+	} `yaml:"auth"`
+	RateLimit struct {
+		Enabled        bool                     `yaml:"enabled"`
+		TrustedProxies []string                 `yaml:"trustedProxies"`
+		IdleTTL        time.Duration            `yaml:"idleTTL"`
+		Groups         map[string]RateLimitRule `yaml:"groups"`
+	} `yaml:"rateLimit"`
+}
+
+// RateLimitRule is a token bucket refilled with Rate tokens per second and
+// holding at most Burst tokens.
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -31,6 +122,11 @@ func LoadConfig() (*Config, error) {
 	viper.AddConfigPath("./")
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
+	// every key can be overridden from the environment, e.g.
+	// QUOTATION_POSTGRES_PASSWORD for postgres.password
+	viper.SetEnvPrefix("quotation")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
 
 	err := viper.ReadInConfig()
 	if err != nil {
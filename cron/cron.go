@@ -1,36 +1,45 @@
 package cronSc
 
 import (
-	"database/sql"
-	"errors"
+	"context"
 	"github.com/go-co-op/gocron"
 	"github.com/google/uuid"
 	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
 	"github.com/mashmorsik/quotation/infrastructure/quote_api"
+	"github.com/mashmorsik/quotation/internal/alert"
 	"github.com/mashmorsik/quotation/pkg/loc"
 	"github.com/mashmorsik/quotation/pkg/models"
 	"github.com/mashmorsik/quotation/repository"
 	errs "github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"sync"
 	"time"
 )
 
+const defaultReloadInterval = time.Minute
+
 type Scheduler struct {
 	sched *gocron.Scheduler
 }
 
 type Data struct {
+	Ctx    context.Context
 	Repo   repository.Repository
+	Alerts *alert.Alert
 	Config *config.Config
-	quotes [][]string
+
+	mu        sync.Mutex
+	scheduler *gocron.Scheduler
+	jobs      map[string]*gocron.Job
 }
 
 func NewScheduler(sched *gocron.Scheduler) *Scheduler {
 	return &Scheduler{sched: sched}
 }
 
-func NewData(repo repository.Repository, conf *config.Config) *Data {
-	return &Data{Repo: repo, Config: conf}
+func NewData(ctx context.Context, repo repository.Repository, alerts *alert.Alert, conf *config.Config) *Data {
+	return &Data{Ctx: ctx, Repo: repo, Alerts: alerts, Config: conf}
 }
 
 func (s *Scheduler) Sc() *gocron.Scheduler {
@@ -47,46 +56,213 @@ func StartScheduler() *gocron.Scheduler {
 	return sched
 }
 
+// ValidateSchedule accepts a Go duration ("10m"), a standard five-field cron
+// expression or a descriptor such as "@hourly" or "@every 1h".
+func ValidateSchedule(schedule string) error {
+	if d, err := time.ParseDuration(schedule); err == nil {
+		if d <= 0 {
+			return errs.New("schedule interval must be positive")
+		}
+		return nil
+	}
+
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return errs.WithMessage(err, "schedule must be a duration or a cron expression")
+	}
+
+	return nil
+}
+
+// RunScheduler starts one refresh job per schedule group and a job that
+// keeps the groups in sync with the tracked pairs.
 func (d *Data) RunScheduler() (*gocron.Scheduler, error) {
 	sc := NewScheduler(StartScheduler())
 	scheduler := sc.Sc()
 	defer scheduler.StartAsync()
 
-	_, err := scheduler.Cron(d.Config.Cron.Period).Do(func() {
-		quotePairs, err := d.Repo.GetQuotePairs()
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				logger.Errf("no quote pairs in database: %v", err)
-			} else {
-				logger.Errf("fail to GetQuotePairs: %v", err)
-				return
-			}
-		}
+	d.mu.Lock()
+	d.scheduler = scheduler
+	d.jobs = make(map[string]*gocron.Job)
+	d.mu.Unlock()
 
-		for _, pair := range quotePairs {
-			rate, err := quote_api.GetQuote(pair[0], pair[1], d.Config)
-			if err != nil {
-				logger.Errf("fail to GetQuote for pair: %v, err: %s", pair, err)
-				return
-			}
+	if err := d.Reconcile(); err != nil {
+		d.mu.Lock()
+		noJobs := len(d.jobs) == 0
+		d.mu.Unlock()
+		if noJobs {
+			return nil, errs.WithMessage(err, "fail to Create CronJob")
+		}
+		logger.Errf("fail to load pair schedules: %v", err)
+	}
 
-			quote := &models.Quote{
-				ID:             uuid.New(),
-				BaseCurrency:   pair[0],
-				TargetCurrency: pair[1],
-				Timestamp:      time.Now(),
-				Rate:           rate,
-			}
-			err = d.Repo.AddQuotation(quote)
-			if err != nil {
-				logger.Errf("fail to AddQuotation: %v", err)
-				return
-			}
+	_, err := scheduler.Every(d.reloadInterval()).WaitForSchedule().Do(func() {
+		if err := d.Reconcile(); err != nil {
+			logger.Errf("fail to reload pair schedules: %v", err)
 		}
 	})
 	if err != nil {
-		return nil, errs.WithMessage(err, "fail to Create CronJob")
+		return nil, errs.WithMessage(err, "fail to Create reload CronJob")
 	}
 
 	return scheduler, nil
 }
+
+// Reconcile makes sure there is exactly one job per schedule in use. The
+// job for the default schedule always exists. Jobs look up their pairs on
+// every run, so moving a pair between schedules only needs the target
+// group's job to exist.
+func (d *Data) Reconcile() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.ensureJob(d.Config.Cron.Period); err != nil {
+		return err
+	}
+
+	pairs, err := d.Repo.GetTrackedPairs(d.Ctx)
+	if err != nil {
+		return errs.WithMessage(err, "fail to GetTrackedPairs")
+	}
+
+	groups := map[string]struct{}{d.Config.Cron.Period: {}}
+	for _, p := range pairs {
+		if !p.Enabled {
+			continue
+		}
+		schedule := d.schedule(p)
+		if err = d.ensureJob(schedule); err != nil {
+			logger.Errf("fail to create job for schedule %q: %v", schedule, err)
+			continue
+		}
+		groups[schedule] = struct{}{}
+	}
+
+	for schedule, job := range d.jobs {
+		if _, ok := groups[schedule]; !ok {
+			d.scheduler.RemoveByReference(job)
+			delete(d.jobs, schedule)
+			logger.Infof("removed refresh job for schedule %q", schedule)
+		}
+	}
+
+	return nil
+}
+
+func (d *Data) ensureJob(schedule string) error {
+	if _, ok := d.jobs[schedule]; ok {
+		return nil
+	}
+
+	s := d.scheduler
+	if interval, err := time.ParseDuration(schedule); err == nil {
+		s = s.Every(interval)
+	} else {
+		s = s.Cron(schedule)
+	}
+
+	job, err := s.Tag(schedule).Do(d.refreshGroup, schedule)
+	if err != nil {
+		return errs.WithMessagef(err, "fail to Create CronJob for %q", schedule)
+	}
+
+	d.jobs[schedule] = job
+	logger.Infof("created refresh job for schedule %q", schedule)
+	return nil
+}
+
+// schedule returns the pair's own schedule, or the global one when the pair
+// has none or it is invalid.
+func (d *Data) schedule(p *models.TrackedPair) string {
+	if p.Schedule == "" || ValidateSchedule(p.Schedule) != nil {
+		return d.Config.Cron.Period
+	}
+	return p.Schedule
+}
+
+// refreshGroup fetches every enabled pair on schedule and stores the quotes
+// as one batch, so a tick is persisted whole or not at all.
+func (d *Data) refreshGroup(schedule string) {
+	pairs, err := d.Repo.GetTrackedPairs(d.Ctx)
+	if err != nil {
+		logger.Errf("fail to GetTrackedPairs: %v", err)
+		return
+	}
+
+	var quotes []*models.Quote
+	for _, p := range pairs {
+		if !p.Enabled || d.schedule(p) != schedule {
+			continue
+		}
+		if quote := d.fetch([]string{p.BaseCurrency, p.TargetCurrency}); quote != nil {
+			quotes = append(quotes, quote)
+		}
+	}
+
+	d.store(quotes)
+}
+
+func (d *Data) fetch(pair []string) *models.Quote {
+	rate, err := quote_api.GetQuote(pair[0], pair[1], d.Config)
+	if err != nil {
+		logger.Errf("fail to GetQuote for pair: %v, err: %s", pair, err)
+		d.recordFetch(pair, err)
+		return nil
+	}
+	d.recordFetch(pair, nil)
+
+	return &models.Quote{
+		ID:             uuid.New(),
+		BaseCurrency:   pair[0],
+		TargetCurrency: pair[1],
+		Timestamp:      time.Now(),
+		Rate:           rate,
+	}
+}
+
+func (d *Data) store(quotes []*models.Quote) {
+	if len(quotes) == 0 {
+		return
+	}
+
+	err := d.Repo.AddQuotations(d.Ctx, quotes)
+	if err != nil {
+		var batchErr *repository.BatchError
+		if errs.As(err, &batchErr) {
+			for _, f := range batchErr.Failed {
+				logger.Errf("fail to AddQuotations for pair: %s/%s, quoteID: %s, err: %s",
+					f.Quote.BaseCurrency, f.Quote.TargetCurrency, f.Quote.ID, f.Err)
+			}
+			logger.Errf("fail to AddQuotations: none of the %d quotes were stored", len(quotes))
+			return
+		}
+		logger.Errf("fail to AddQuotations: %v", err)
+		return
+	}
+
+	if d.Alerts == nil {
+		return
+	}
+	for _, quote := range quotes {
+		if _, err = d.Alerts.Evaluate(d.Ctx, quote); err != nil {
+			logger.Errf("fail to evaluate alerts for pair: %s/%s, err: %s", quote.BaseCurrency, quote.TargetCurrency, err)
+		}
+	}
+}
+
+func (d *Data) recordFetch(pair []string, fetchErr error) {
+	var errStr string
+	if fetchErr != nil {
+		errStr = fetchErr.Error()
+	}
+
+	if err := d.Repo.RecordQuotePairFetch(d.Ctx, pair[0], pair[1], time.Now(), errStr); err != nil {
+		logger.Errf("fail to RecordQuotePairFetch for pair: %v, err: %s", pair, err)
+	}
+}
+
+func (d *Data) reloadInterval() time.Duration {
+	if d.Config.Cron.ReloadInterval <= 0 {
+		return defaultReloadInterval
+	}
+	return d.Config.Cron.ReloadInterval
+}
@@ -0,0 +1,90 @@
+package cronSc
+
+import (
+	"context"
+	"github.com/go-co-op/gocron"
+	"github.com/golang/mock/gomock"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		wantErr  bool
+	}{
+		{schedule: "10m"},
+		{schedule: "*/5 * * * *"},
+		{schedule: "@hourly"},
+		{schedule: "@every 1h"},
+		{schedule: "-1m", wantErr: true},
+		{schedule: "often", wantErr: true},
+		{schedule: "* * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			if err := ValidateSchedule(tt.schedule); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func jobSchedules(d *Data) []string {
+	var schedules []string
+	for s := range d.jobs {
+		schedules = append(schedules, s)
+	}
+	sort.Strings(schedules)
+	return schedules
+}
+
+func TestData_Reconcile(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conf := &config.Config{}
+	conf.Cron.Period = "*/2 * * * *"
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	gomock.InOrder(
+		mockRepo.EXPECT().GetTrackedPairs(gomock.Any()).Return([]*models.TrackedPair{
+			{BaseCurrency: "EUR", TargetCurrency: "USD", Enabled: true},
+			{BaseCurrency: "EUR", TargetCurrency: "MXN", Enabled: true, Schedule: "10m"},
+			{BaseCurrency: "USD", TargetCurrency: "MXN", Enabled: true, Schedule: "10m"},
+			{BaseCurrency: "MXN", TargetCurrency: "USD", Enabled: false, Schedule: "@hourly"},
+			{BaseCurrency: "MXN", TargetCurrency: "EUR", Enabled: true, Schedule: "bogus"},
+		}, nil),
+		mockRepo.EXPECT().GetTrackedPairs(gomock.Any()).Return([]*models.TrackedPair{
+			{BaseCurrency: "EUR", TargetCurrency: "MXN", Enabled: true, Schedule: "@hourly"},
+		}, nil),
+	)
+
+	d := NewData(context.Background(), mockRepo, nil, conf)
+	d.scheduler = gocron.NewScheduler(time.UTC)
+	d.jobs = make(map[string]*gocron.Job)
+
+	if err := d.Reconcile(); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got := jobSchedules(d); len(got) != 2 || got[0] != "*/2 * * * *" || got[1] != "10m" {
+		t.Errorf("jobs after first Reconcile() = %v", got)
+	}
+
+	if err := d.Reconcile(); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got := jobSchedules(d); len(got) != 2 || got[0] != "*/2 * * * *" || got[1] != "@hourly" {
+		t.Errorf("jobs after second Reconcile() = %v", got)
+	}
+	if n := len(d.scheduler.Jobs()); n != 2 {
+		t.Errorf("scheduler has %d jobs, want 2", n)
+	}
+}
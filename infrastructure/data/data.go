@@ -7,82 +7,462 @@ import (
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/lib/pq"
 	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/migration"
+	errs "github.com/pkg/errors"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
 )
 
+const defaultReplicaCheckInterval = 5 * time.Second
+
 type Data struct {
-	Ctx context.Context
-	db  *sql.DB
+	Ctx      context.Context
+	db       *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
 }
 
-func NewData(ctx context.Context, db *sql.DB) *Data {
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// NewData wraps the primary and optional read replicas. Replicas take reads
+// only after RunHealthCheck found them healthy.
+func NewData(ctx context.Context, db *sql.DB, replicas ...*sql.DB) *Data {
 	if db == nil {
 		panic("db is nil")
 	}
-	return &Data{Ctx: ctx, db: db}
+
+	d := &Data{Ctx: ctx, db: db}
+	for _, r := range replicas {
+		d.replicas = append(d.replicas, &replica{db: r})
+	}
+	return d
 }
 
 func (r *Data) Master() *sql.DB {
 	return r.db
 }
 
-func MustConnectPostgres(ctx context.Context, conf *config.Config) *sql.DB {
-	connectionStr := fmt.Sprintf("postgres://postgres:mysecretpassword@%s:%s/postgres?sslmode=disable&application_name=quotation&connect_timeout=5",
-		conf.Postgres.Host, conf.Postgres.Port)
+// Querier is what *sql.DB and *sql.Tx have in common, so repositories can
+// run the same statements in and outside a transaction.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
-	connection, err := sql.Open("postgres", connectionStr)
+// WithTx runs fn in a transaction on the primary. The transaction is
+// committed if fn returns nil and rolled back otherwise.
+func (r *Data) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, opts)
 	if err != nil {
-		panic(err)
+		return errs.WithMessage(err, "failed to begin transaction")
 	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	if err = connection.Ping(); err != nil {
-		panic(err)
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errs.WithMessage(err, "failed to commit transaction")
+	}
+	return nil
+}
+
+// Replica returns the next healthy replica in round-robin order, or the
+// primary when there is none.
+func (r *Data) Replica() *sql.DB {
+	n := len(r.replicas)
+	if n == 0 {
+		return r.db
+	}
+
+	start := r.next.Add(1)
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+uint64(i))%uint64(n)]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+
+	return r.db
+}
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so that reads made with it go to the primary. Use it
+// to read rows written moments before, which replicas may not have yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// IsPrimary reports whether ctx was marked by WithPrimary.
+func IsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// Reader returns the database reads made with ctx should use.
+func (r *Data) Reader(ctx context.Context) *sql.DB {
+	if IsPrimary(ctx) {
+		return r.db
+	}
+	return r.Replica()
+}
+
+// HasReplicas reports whether reads may be served by a replica.
+func (r *Data) HasReplicas() bool {
+	return len(r.replicas) > 0
+}
+
+// RunHealthCheck pings every replica each interval until Ctx is done and
+// takes failing ones out of rotation.
+func (r *Data) RunHealthCheck(interval time.Duration) {
+	if len(r.replicas) == 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.checkReplicas(interval)
+
+		select {
+		case <-r.Ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Data) checkReplicas(timeout time.Duration) {
+	for i, rep := range r.replicas {
+		ctx, cancel := context.WithTimeout(r.Ctx, timeout)
+		err := rep.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.Infof("replica %d is healthy", i)
+			} else {
+				logger.Errf("replica %d is unhealthy: %v", i, err)
+			}
+		}
+	}
+}
+
+// PostgresDSN returns postgres.dsn or builds one from the connection fields,
+// reading the password from postgres.passwordFile when it is set.
+func PostgresDSN(conf *config.Config) (string, error) {
+	pg := conf.Postgres
+	if pg.DSN != "" {
+		return pg.DSN, nil
+	}
+
+	password := pg.Password
+	if pg.PasswordFile != "" {
+		b, err := os.ReadFile(pg.PasswordFile)
+		if err != nil {
+			return "", errs.WithMessage(err, "failed to read postgres password file")
+		}
+		password = strings.TrimRight(string(b), "\r\n")
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", valueOr(pg.SSLMode, "disable"))
+	for param, v := range map[string]string{
+		"sslrootcert": pg.SSLRootCert,
+		"sslcert":     pg.SSLCert,
+		"sslkey":      pg.SSLKey,
+	} {
+		if v != "" {
+			query.Set(param, v)
+		}
+	}
+	query.Set("application_name", "quotation")
+	connectTimeout := pg.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = 5 * time.Second
+	}
+	query.Set("connect_timeout", strconv.Itoa(int(connectTimeout.Seconds())))
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(valueOr(pg.User, "postgres"), password),
+		Host:     net.JoinHostPort(pg.Host, pg.Port),
+		Path:     "/" + valueOr(pg.Database, "postgres"),
+		RawQuery: query.Encode(),
+	}
+	return u.String(), nil
+}
+
+func valueOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// ApplyPool applies the configured pool limits to db; zero values keep the
+// database/sql defaults.
+func ApplyPool(db *sql.DB, conf *config.Config) {
+	pg := conf.Postgres
+	if pg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pg.MaxOpenConns)
+	}
+	if pg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pg.MaxIdleConns)
+	}
+	if pg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pg.ConnMaxLifetime)
+	}
+	if pg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pg.ConnMaxIdleTime)
+	}
+}
+
+// OpenPostgres prepares the primary connection pool without connecting; see
+// WaitForPostgres. The pool is closed once ctx is done.
+func OpenPostgres(ctx context.Context, conf *config.Config) (*sql.DB, error) {
+	connectionStr, err := PostgresDSN(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := sql.Open("postgres", connectionStr)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to open postgres")
 	}
+	ApplyPool(connection, conf)
 
 	go func() {
 		<-ctx.Done()
-		err = connection.Close()
+		err := connection.Close()
 		if err != nil {
 			logger.Errf("can't close database connection, err: %s", err)
 			return
 		}
 	}()
 
+	return connection, nil
+}
+
+// WaitForPostgres pings the primary, retrying with backoff, until it answers.
+func WaitForPostgres(ctx context.Context, connection *sql.DB, conf *config.Config) error {
+	err := Retry(ctx, conf, "connect", func(ctx context.Context) error {
+		return connection.PingContext(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
 	logger.Infof("connected to db: %+v", connection.Stats())
-	return connection
+	return nil
 }
 
-func MustMigrate(connection *sql.DB) {
-	driver, err := postgres.WithInstance(connection, &postgres.Config{})
+// ReplicaDSN returns the connection string of a replica. A replica given as
+// a host, optionally with a port, shares every other setting of the primary,
+// such as the password file, TLS and the connect timeout; anything else is
+// taken as a complete DSN.
+func ReplicaDSN(conf *config.Config, replica string) (string, error) {
+	if strings.Contains(replica, "://") || strings.Contains(replica, "=") {
+		return replica, nil
+	}
+	if conf.Postgres.DSN != "" {
+		return "", errs.Errorf("replica %q is a host, which needs the primary set by its fields, not by dsn", replica)
+	}
+
+	host, port := replica, conf.Postgres.Port
+	if h, p, err := net.SplitHostPort(replica); err == nil {
+		host, port = h, p
+	}
+
+	replicaConf := *conf
+	replicaConf.Postgres.Host, replicaConf.Postgres.Port = host, port
+	return PostgresDSN(&replicaConf)
+}
+
+// ConnectReplicas opens the configured replicas. Connections are
+// established lazily, RunHealthCheck decides whether they are used.
+func ConnectReplicas(ctx context.Context, conf *config.Config) ([]*sql.DB, error) {
+	dsns := make([]string, 0, len(conf.Postgres.Replicas))
+	for _, replica := range conf.Postgres.Replicas {
+		dsn, err := ReplicaDSN(conf, replica)
+		if err != nil {
+			return nil, err
+		}
+		dsns = append(dsns, dsn)
+	}
+
+	var replicas []*sql.DB
+	for _, dsn := range dsns {
+		connection, err := sql.Open("postgres", dsn)
+		if err != nil {
+			for _, r := range replicas {
+				_ = r.Close()
+			}
+			return nil, errs.WithMessage(err, "failed to open postgres replica")
+		}
+		ApplyPool(connection, conf)
+
+		go func() {
+			<-ctx.Done()
+			if err := connection.Close(); err != nil {
+				logger.Errf("can't close replica connection, err: %s", err)
+			}
+		}()
+
+		replicas = append(replicas, connection)
+	}
+
+	return replicas, nil
+}
+
+// MigratePostgres applies the embedded migrations, retrying with backoff
+// while the database is unreachable.
+func MigratePostgres(ctx context.Context, connection *sql.DB, conf *config.Config) error {
+	return Retry(ctx, conf, "migrate", func(ctx context.Context) error {
+		return migrateConn(ctx, connection)
+	})
+}
+
+// Migrate applies the embedded Postgres migrations.
+func Migrate(connection *sql.DB) error {
+	return migrateConn(context.Background(), connection)
+}
+
+// migrateConn runs the migrations on a connection of the pool, given back
+// to it afterwards whatever the outcome, so retries do not leak connections.
+func migrateConn(ctx context.Context, connection *sql.DB) error {
+	conn, err := connection.Conn(ctx)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer func() {
+		if err := conn.Close(); err != nil && !errors.Is(err, sql.ErrConnDone) {
+			logger.Errf("can't close migration connection, err: %s", err)
+		}
+	}()
 
-	path, err := os.Getwd()
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	migrationPath := fmt.Sprintf("file://%s/migration", path)
-	fmt.Printf("migrationPath : %s\n", migrationPath)
+	source, err := iofs.New(migration.Postgres, ".")
+	if err != nil {
+		return err
+	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		migrationPath,
-		"postgres", driver)
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	// closes conn, not the pool, as the driver does not own it
+	defer m.Close()
+
+	return up(m)
+}
 
-	if err = m.Up(); err != nil {
+// NewPostgresMigrate returns a migrate instance over the embedded Postgres
+// migrations. Closing it closes connection too.
+func NewPostgresMigrate(connection *sql.DB) (*migrate.Migrate, error) {
+	driver, err := postgres.WithInstance(connection, &postgres.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := iofs.New(migration.Postgres, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("iofs", source, "postgres", driver)
+}
+
+func up(m *migrate.Migrate) error {
+	if err := m.Up(); err != nil {
 		if errors.Is(err, migrate.ErrNoChange) {
 			logger.Infof("no changes in migration, skip")
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ConnectSQLite opens the SQLite database file from the config. SQLite
+// serialises writers, so a single connection avoids SQLITE_BUSY errors.
+func ConnectSQLite(ctx context.Context, conf *config.Config) (*sql.DB, error) {
+	connectionStr := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)",
+		conf.Storage.SQLite.Path)
+
+	connection, err := sql.Open("sqlite", connectionStr)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to open sqlite")
+	}
+	connection.SetMaxOpenConns(1)
+
+	if err = connection.Ping(); err != nil {
+		_ = connection.Close()
+		return nil, errs.WithMessagef(err, "failed to open sqlite database %s", conf.Storage.SQLite.Path)
+	}
 
-		} else {
-			panic(err)
+	go func() {
+		<-ctx.Done()
+		if err := connection.Close(); err != nil {
+			logger.Errf("can't close database connection, err: %s", err)
 		}
+	}()
+
+	logger.Infof("connected to sqlite db: %s", conf.Storage.SQLite.Path)
+	return connection, nil
+}
+
+// MigrateSQLite applies the embedded SQLite migrations.
+func MigrateSQLite(connection *sql.DB) error {
+	m, err := NewSQLiteMigrate(connection)
+	if err != nil {
+		return err
+	}
+	return up(m)
+}
+
+// NewSQLiteMigrate returns a migrate instance over the embedded SQLite
+// migrations. Closing it closes connection too.
+func NewSQLiteMigrate(connection *sql.DB) (*migrate.Migrate, error) {
+	driver, err := sqlite.WithInstance(connection, &sqlite.Config{})
+	if err != nil {
+		return nil, err
 	}
+
+	source, err := iofs.New(migration.SQLite, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("iofs", source, "sqlite", driver)
 }
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lib/pq"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"sync"
+	"time"
+)
+
+// QuotationChannel is notified with the quote as JSON for every row
+// inserted into quotation, by the trigger of migration 000011.
+const QuotationChannel = "quotation"
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	// listenerPingInterval checks the connection after this long without
+	// notifications, so a dead one is noticed and reconnected.
+	listenerPingInterval = 90 * time.Second
+)
+
+// Listener LISTENs on QuotationChannel on the primary and hands every
+// inserted quote to the subscribed handlers, whichever instance inserted it.
+type Listener struct {
+	dsn      string
+	mu       sync.RWMutex
+	handlers []func(q *models.Quote)
+}
+
+func NewListener(conf *config.Config) (*Listener, error) {
+	dsn, err := PostgresDSN(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Listener{dsn: dsn}, nil
+}
+
+// Subscribe calls fn for every inserted quote. fn gets nil after the
+// connection was lost, when notifications may have been missed. It must not
+// block, notifications wait for it.
+func (l *Listener) Subscribe(fn func(q *models.Quote)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.handlers = append(l.handlers, fn)
+}
+
+// Run listens until ctx is done, reconnecting with backoff whenever the
+// connection drops.
+func (l *Listener) Run(ctx context.Context) {
+	pl := pq.NewListener(l.dsn, listenerMinReconnect, listenerMaxReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Errf("quotation listener: %v", err)
+		}
+	})
+	go func() {
+		<-ctx.Done()
+		_ = pl.Close()
+	}()
+
+	if err := pl.Listen(QuotationChannel); err != nil {
+		if ctx.Err() == nil {
+			logger.Errf("failed to listen on %s: %v", QuotationChannel, err)
+		}
+		return
+	}
+	logger.Infof("listening for notifications on %s", QuotationChannel)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-pl.Notify:
+			if !ok {
+				return
+			}
+			l.dispatch(n)
+		case <-time.After(listenerPingInterval):
+			go func() { _ = pl.Ping() }()
+		}
+	}
+}
+
+// dispatch hands n to the handlers. pq sends a nil notification once it
+// has reconnected.
+func (l *Listener) dispatch(n *pq.Notification) {
+	var q *models.Quote
+	if n != nil {
+		q = &models.Quote{}
+		if err := json.Unmarshal([]byte(n.Extra), q); err != nil {
+			logger.Errf("failed to decode %s notification %q: %v", n.Channel, n.Extra, err)
+			return
+		}
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, fn := range l.handlers {
+		fn(q)
+	}
+}
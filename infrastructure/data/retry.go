@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	errs "github.com/pkg/errors"
+	"time"
+)
+
+const (
+	defaultRetryAttempts   = 10
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// Retry calls fn until it succeeds, postgres.retryAttempts is used up or
+// ctx is done. The wait between attempts starts at postgres.retryBackoff and
+// doubles up to postgres.retryMaxBackoff.
+func Retry(ctx context.Context, conf *config.Config, op string, fn func(ctx context.Context) error) error {
+	attempts := conf.Postgres.RetryAttempts
+	if attempts <= 0 {
+		attempts = defaultRetryAttempts
+	}
+	backoff := conf.Postgres.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := conf.Postgres.RetryMaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				logger.Nlog.Info().Str("op", op).Int("attempt", attempt).Msg("database operation succeeded after retrying")
+			}
+			return nil
+		}
+		if attempt >= attempts {
+			return errs.WithMessagef(err, "%s failed after %d attempts", op, attempt)
+		}
+
+		logger.Nlog.Warn().Err(err).Str("op", op).Int("attempt", attempt).Int("maxAttempts", attempts).
+			Dur("retryIn", backoff).Msg("database operation failed, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errs.WithMessagef(ctx.Err(), "%s cancelled after %d attempts, last error: %v", op, attempt, err)
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
@@ -32,7 +32,7 @@ func GetQuote(from, to string, conf *config.Config) (decimal.Decimal, error) {
 	}(res.Body)
 
 	if res.StatusCode != http.StatusOK {
-		return decimal.Zero, errs.WithMessagef(err, "invalid response status: %v", res.StatusCode)
+		return decimal.Zero, errs.Errorf("invalid response status: %v", res.StatusCode)
 	}
 
 	body, err := io.ReadAll(res.Body)
@@ -47,7 +47,7 @@ func GetQuote(from, to string, conf *config.Config) (decimal.Decimal, error) {
 
 	rate, found := response.Rates[to]
 	if !found {
-		return decimal.Zero, errs.WithMessagef(err, "failed to find %s rate", to)
+		return decimal.Zero, errs.Errorf("failed to find %s rate", to)
 	}
 
 	return rate, nil
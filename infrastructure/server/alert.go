@@ -0,0 +1,198 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/internal/alert"
+	"github.com/mashmorsik/quotation/pkg/currency"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"net/http"
+)
+
+func (s *HTTPServer) validateAlertRule(req *models.AlertRuleRequest) error {
+	if err := s.validateQuote(req.Quote); err != nil {
+		return err
+	}
+	if err := alert.ValidateComparator(req.Comparator); err != nil {
+		return err
+	}
+	if req.ChangeWindow < 0 || req.Cooldown < 0 {
+		return errors.New("durations must not be negative")
+	}
+	return nil
+}
+
+func decodeAlertRuleRequest(r *http.Request) (*models.AlertRuleRequest, error) {
+	var req models.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.New("Failed to parse JSON body")
+	}
+	return &req, nil
+}
+
+func pathID(r *http.Request) (uuid.UUID, error) {
+	return uuid.Parse(mux.Vars(r)["id"])
+}
+
+func (s *HTTPServer) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.Alerts.GetRules(r.Context())
+	if err != nil {
+		logger.Errf("fail to GetRules: %v", err)
+		http.Error(w, "fail to GetRules", http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = []*models.AlertRule{}
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *HTTPServer) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeAlertRuleRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateAlertRule(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to := currency.SeparateCurrency(req.Quote)
+	rule := &models.AlertRule{
+		BaseCurrency:   from,
+		TargetCurrency: to,
+		Comparator:     req.Comparator,
+		Threshold:      req.Threshold,
+		ChangeWindow:   req.ChangeWindow,
+		Cooldown:       req.Cooldown,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+	}
+
+	rule, err = s.Alerts.CreateRule(r.Context(), rule)
+	if err != nil {
+		logger.Errf("fail to CreateRule for %s: %v", req.Quote, err)
+		http.Error(w, "fail to CreateRule", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *HTTPServer) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := s.Alerts.GetRule(r.Context(), id)
+	if err != nil {
+		logger.Errf("fail to GetRule %s: %v", id, err)
+		http.Error(w, "fail to GetRule", http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *HTTPServer) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	req, err := decodeAlertRuleRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateAlertRule(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule, err := s.Alerts.GetRule(r.Context(), id)
+	if err != nil {
+		logger.Errf("fail to GetRule %s: %v", id, err)
+		http.Error(w, "fail to GetRule", http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+		return
+	}
+
+	rule.BaseCurrency, rule.TargetCurrency = currency.SeparateCurrency(req.Quote)
+	rule.Comparator = req.Comparator
+	rule.Threshold = req.Threshold
+	rule.ChangeWindow = req.ChangeWindow
+	rule.Cooldown = req.Cooldown
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	if err = s.Alerts.UpdateRule(r.Context(), rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Alert rule not found", http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to UpdateRule %s: %v", id, err)
+		http.Error(w, "fail to UpdateRule", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *HTTPServer) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err = s.Alerts.DeleteRule(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Alert rule not found", http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to DeleteRule %s: %v", id, err)
+		http.Error(w, "fail to DeleteRule", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) GetAlertFirings(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	firings, err := s.Alerts.GetFirings(r.Context(), id)
+	if err != nil {
+		logger.Errf("fail to GetFirings %s: %v", id, err)
+		http.Error(w, "fail to GetFirings", http.StatusInternalServerError)
+		return
+	}
+	if firings == nil {
+		firings = []*models.AlertFiring{}
+	}
+
+	writeJSON(w, http.StatusOK, firings)
+}
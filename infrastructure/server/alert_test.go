@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/internal/alert"
+	"github.com/mashmorsik/quotation/internal/quotation"
+	"github.com/mashmorsik/quotation/pkg/models"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPServer_CreateAlertRule(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alertRepo := mock_repository.NewMockAlertRepository(ctrl)
+	alertRepo.EXPECT().AddAlertRule(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	conf := &config.Config{
+		Quotations: []string{"EUR", "MXN", "USD"},
+	}
+	srv := NewServer(conf, quotation.Quotation{Config: conf})
+	srv.Alerts = alert.NewAlert(alertRepo, nil, conf)
+	testServer := httptest.NewServer(http.HandlerFunc(srv.CreateAlertRule))
+	defer testServer.Close()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid_rule",
+			body:       `{"quote":"EUR/MXN","comparator":">","threshold":"20.0","cooldown":"30m"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "invalid_comparator",
+			body:       `{"quote":"EUR/MXN","comparator":"=","threshold":"20.0"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid_pair",
+			body:       `{"quote":"EUR/GBP","comparator":">","threshold":"20.0"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid_duration",
+			body:       `{"quote":"EUR/MXN","comparator":">","threshold":"20.0","cooldown":"soon"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(testServer.URL+"/alerts", "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("Error creating alert rule: %v", err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Unexpected status code: %v", resp.StatusCode)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			got := &models.AlertRule{}
+			if err = json.NewDecoder(resp.Body).Decode(got); err != nil {
+				t.Fatalf("Error unmarshalling response body: %v", err)
+			}
+			if got.BaseCurrency != "EUR" || got.TargetCurrency != "MXN" || !got.Enabled ||
+				time.Duration(got.Cooldown) != 30*time.Minute {
+				t.Errorf("Unexpected rule: %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package server
+
+import "net/http"
+
+// GetCacheStats reports the hit and miss counters of the repository cache.
+func (s *HTTPServer) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Cache.CacheStats())
+}
@@ -9,27 +9,86 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/internal/alert"
+	"github.com/mashmorsik/quotation/internal/auth"
 	"github.com/mashmorsik/quotation/internal/quotation"
+	"github.com/mashmorsik/quotation/internal/ratelimit"
+	"github.com/mashmorsik/quotation/internal/webhook"
 	"github.com/mashmorsik/quotation/pkg/currency"
 	mw "github.com/mashmorsik/quotation/pkg/middleware"
 	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
 	"github.com/pkg/errors"
 	"github.com/rs/cors"
 	"golang.org/x/sync/errgroup"
 	"io"
 	"net/http"
+	"sync/atomic"
 )
 
 type HTTPServer struct {
-	Config *config.Config
-	Quote  quotation.Quotation
+	Config   *config.Config
+	Quote    quotation.Quotation
+	Alerts   *alert.Alert
+	Webhooks *webhook.Webhook
+	Auth     *auth.Auth
+	Limiter  *ratelimit.Limiter
+	// Cache, when set, is the caching repository behind Quote.
+	Cache *repository.CachedRepo
+
+	// ready is false until the storage behind the API can be used.
+	ready atomic.Bool
 }
 
 func NewServer(conf *config.Config, quote quotation.Quotation) *HTTPServer {
 	return &HTTPServer{Config: conf, Quote: quote}
 }
 
+// SetReady switches the API between serving and answering 503.
+func (s *HTTPServer) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Healthz reports that the process is up.
+func (s *HTTPServer) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// Readyz reports whether the API can serve requests.
+func (s *HTTPServer) Readyz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ready"))
+}
+
+// readiness answers 503 on every API route until the server is ready.
+// Health checks and the docs are always served.
+func (s *HTTPServer) readiness(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() && !alwaysServed[r.URL.Path] {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Service is starting", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var alwaysServed = map[string]bool{
+	"/healthz":      true,
+	"/readyz":       true,
+	"/swagger":      true,
+	"/swagger.yaml": true,
+}
+
 func (s *HTTPServer) StartServer(ctx context.Context) error {
+	if s.Config.Auth.Enabled && s.Auth == nil {
+		return errors.New("auth is enabled, but there is no API key store")
+	}
 
 	router := mux.NewRouter()
 
@@ -40,13 +99,50 @@ func (s *HTTPServer) StartServer(ctx context.Context) error {
 	}, nil)
 	router.Handle("/swagger", sh)
 
-	router.HandleFunc("/update", s.UpdateQuote).Methods(http.MethodPost)
-	router.HandleFunc("/get", s.GetQuote).Methods(http.MethodGet)
-	router.HandleFunc("/latest", s.GetLatestQuote).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", s.Healthz).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", s.Readyz).Methods(http.MethodGet)
+
+	router.Handle("/update", s.guarded(models.ScopeQuotesWrite, "update", s.UpdateQuote)).Methods(http.MethodPost)
+	router.Handle("/get", s.guarded(models.ScopeQuotesRead, "read", s.GetQuote)).Methods(http.MethodGet)
+	router.Handle("/latest", s.guarded(models.ScopeQuotesRead, "read", s.GetLatestQuote)).Methods(http.MethodGet)
+	router.Handle("/stats", s.guarded(models.ScopeQuotesRead, "read", s.GetStats)).Methods(http.MethodGet)
+	router.Handle("/matrix", s.guarded(models.ScopeQuotesRead, "read", s.GetMatrix)).Methods(http.MethodGet)
+
+	router.Handle("/pairs", s.guarded(models.ScopeQuotesRead, "read", s.ListPairs)).Methods(http.MethodGet)
+	router.Handle("/pairs", s.guarded(models.ScopeAdmin, "admin", s.AddPair)).Methods(http.MethodPost)
+	router.Handle("/pairs/{base}/{target}", s.guarded(models.ScopeQuotesRead, "read", s.GetPair)).Methods(http.MethodGet)
+	router.Handle("/pairs/{base}/{target}", s.guarded(models.ScopeAdmin, "admin", s.UpdatePair)).Methods(http.MethodPatch)
+	router.Handle("/pairs/{base}/{target}", s.guarded(models.ScopeAdmin, "admin", s.DeletePair)).Methods(http.MethodDelete)
+
+	if s.Cache != nil {
+		router.Handle("/cache", s.guarded(models.ScopeAdmin, "admin", s.GetCacheStats)).Methods(http.MethodGet)
+	}
+
+	if s.Alerts != nil {
+		router.Handle("/alerts", s.guarded(models.ScopeAdmin, "admin", s.ListAlertRules)).Methods(http.MethodGet)
+		router.Handle("/alerts", s.guarded(models.ScopeAdmin, "admin", s.CreateAlertRule)).Methods(http.MethodPost)
+		router.Handle("/alerts/{id}", s.guarded(models.ScopeAdmin, "admin", s.GetAlertRule)).Methods(http.MethodGet)
+		router.Handle("/alerts/{id}", s.guarded(models.ScopeAdmin, "admin", s.UpdateAlertRule)).Methods(http.MethodPut)
+		router.Handle("/alerts/{id}", s.guarded(models.ScopeAdmin, "admin", s.DeleteAlertRule)).Methods(http.MethodDelete)
+		router.Handle("/alerts/{id}/firings", s.guarded(models.ScopeAdmin, "admin", s.GetAlertFirings)).Methods(http.MethodGet)
+	}
+
+	if s.Webhooks != nil {
+		router.Handle("/webhooks", s.guarded(models.ScopeAdmin, "admin", s.ListWebhookEndpoints)).Methods(http.MethodGet)
+		router.Handle("/webhooks", s.guarded(models.ScopeAdmin, "admin", s.CreateWebhookEndpoint)).Methods(http.MethodPost)
+		router.Handle("/webhooks/{id}", s.guarded(models.ScopeAdmin, "admin", s.GetWebhookEndpoint)).Methods(http.MethodGet)
+		router.Handle("/webhooks/{id}", s.guarded(models.ScopeAdmin, "admin", s.UpdateWebhookEndpoint)).Methods(http.MethodPatch)
+		router.Handle("/webhooks/{id}", s.guarded(models.ScopeAdmin, "admin", s.DeleteWebhookEndpoint)).Methods(http.MethodDelete)
+		router.Handle("/webhooks/{id}/deliveries", s.guarded(models.ScopeAdmin, "admin", s.GetWebhookDeliveries)).Methods(http.MethodGet)
+	}
 
 	logger.Infof("HTTPServer is listening on port: %s\n", s.Config.Server.Port)
 
 	router.Use(mw.LoggingMiddleware)
+	router.Use(s.readiness)
+	if s.Auth != nil {
+		router.Use(s.Auth.Middleware)
+	}
 
 	httpServer := &http.Server{
 		Addr:    s.Config.Server.Port,
@@ -69,6 +165,25 @@ func (s *HTTPServer) StartServer(ctx context.Context) error {
 	return nil
 }
 
+// guarded applies, in this order, the scope a route requires, the rate
+// limit of its group and the daily quota of the API key, so a request is
+// only counted against the quota once the other two let it through. Routes
+// are open when authentication is disabled and unlimited when rate limiting
+// is.
+func (s *HTTPServer) guarded(scope, group string, h http.HandlerFunc) http.Handler {
+	var next http.Handler = h
+	if s.Auth != nil {
+		next = s.Auth.Meter(next)
+	}
+	if s.Limiter != nil {
+		next = s.Limiter.Wrap(group, next.ServeHTTP)
+	}
+	if s.Auth != nil {
+		next = s.Auth.Require(scope)(next)
+	}
+	return next
+}
+
 func (s *HTTPServer) UpdateQuote(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -89,9 +204,25 @@ func (s *HTTPServer) UpdateQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if reqBody.CallbackURL != "" {
+		if s.Quote.Webhooks == nil {
+			http.Error(w, "callbacks are disabled", http.StatusBadRequest)
+			return
+		}
+		if err = s.Quote.Webhooks.ValidateURL(r.Context(), reqBody.CallbackURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	from, to := currency.SeparateCurrency(reqBody.Quote)
 
-	quoteID, err := s.Quote.GetQuoteAsync(from, to)
+	quoteID, err := s.Quote.UpdateQuote(r.Context(), from, to, reqBody.CallbackURL)
+	if errors.Is(err, repository.ErrQuotePairRemoved) {
+		http.Error(w, fmt.Sprintf("%s/%s was removed, add it with POST /pairs to track it again", from, to),
+			http.StatusConflict)
+		return
+	}
 	if err != nil {
 		logger.Errf("fail to GetQuoteAsync, for %s/%s", from, to)
 		http.Error(w, "fail to GetQuoteAsync", http.StatusInternalServerError)
@@ -124,7 +255,7 @@ func (s *HTTPServer) GetQuote(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid id", http.StatusBadRequest)
 	}
 
-	quote, err := s.Quote.GetQuotationByID(u)
+	quote, err := s.Quote.GetQuotationByID(r.Context(), u)
 	if err != nil {
 		http.Error(w, "Failed to get quote", http.StatusNotFound)
 	}
@@ -147,6 +278,11 @@ func (s *HTTPServer) GetQuote(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *HTTPServer) GetLatestQuote(w http.ResponseWriter, r *http.Request) {
+	if base := r.URL.Query().Get("base"); base != "" {
+		s.getLatestForBase(w, r, base)
+		return
+	}
+
 	qPair := r.URL.Query().Get("quote")
 
 	if err := s.validateQuote(qPair); err != nil {
@@ -157,10 +293,11 @@ func (s *HTTPServer) GetLatestQuote(w http.ResponseWriter, r *http.Request) {
 
 	from, to := currency.SeparateCurrency(qPair)
 
-	quote, err := s.Quote.GetLastUpdated(from, to)
+	quote, err := s.Quote.GetLastUpdated(r.Context(), from, to)
 	if err != nil {
 		errStr := fmt.Sprintf("Fail to get last updated quote: %v", err)
 		http.Error(w, errStr, http.StatusNotFound)
+		return
 	}
 
 	latestResponse := &models.LatestResponse{
@@ -184,3 +321,21 @@ func (s *HTTPServer) GetLatestQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		logger.Errf("failed to marshal JSON: %v", err)
+		http.Error(w, "Failed to marshal JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(jsonData)
+	if err != nil {
+		logger.Errf("failed to write response: %v", err)
+		return
+	}
+}
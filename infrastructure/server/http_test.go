@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
 	"github.com/mashmorsik/quotation/internal/quotation"
+	"github.com/mashmorsik/quotation/internal/stats"
 	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
 	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
 	"github.com/shopspring/decimal"
 	"io"
@@ -40,17 +43,16 @@ func TestHTTPServer_UpdateQuote(t *testing.T) {
 	}
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().AddQuotePair(from, to).Return(nil)
-	mockRepo.EXPECT().GetLastUpdated(from, to).Return(latestQuote, nil)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().LockQuotePair(gomock.Any(), from, to).Return(nil)
+	mockRepo.EXPECT().AddQuotePair(gomock.Any(), from, to).Return(true, nil)
+	mockRepo.EXPECT().GetLastUpdated(gomock.Any(), from, to).Return(latestQuote, nil)
 
 	conf := &config.Config{
 		ResponseDelay: 5 * time.Second,
 		Quotations:    []string{"EUR", "MXN", "USD"},
-		QuoteAPI: struct {
-			URL string `yaml:"url"`
-		}{
-			URL: "https://api.frankfurter.app/latest?from=%s&to=%s"},
 	}
+	conf.QuoteAPI.URL = upstreamURL(t, `{"base":"%s","rates":{"%s":%s}}`, from, to, latestQuote.Rate)
 	q := &quotation.Quotation{
 		Ctx:    context.Background(),
 		Repo:   mockRepo,
@@ -117,7 +119,7 @@ func TestHTTPServer_GetQuote(t *testing.T) {
 	}
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().GetQuotation(id).Return(quote, nil)
+	mockRepo.EXPECT().GetQuotation(gomock.Any(), id).Return(quote, nil)
 
 	conf := &config.Config{
 		ResponseDelay: 2 * time.Second,
@@ -187,19 +189,18 @@ func TestHTTPServer_GetLatestQuote_quote_not_in_database(t *testing.T) {
 	}
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().GetQuotePairs().Return([][]string{}, nil)
-	mockRepo.EXPECT().AddQuotePair(from, to).Return(nil)
-	mockRepo.EXPECT().GetLastUpdated(from, to).Return(latestQuote, nil)
-	mockRepo.EXPECT().GetQuotation(latestID).Return(latestQuote, nil)
+	mockRepo.EXPECT().GetQuotePairs(gomock.Any()).Return([][]string{}, nil)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().LockQuotePair(gomock.Any(), from, to).Return(nil)
+	mockRepo.EXPECT().AddQuotePair(gomock.Any(), from, to).Return(true, nil)
+	mockRepo.EXPECT().GetLastUpdated(gomock.Any(), from, to).Return(latestQuote, nil)
+	mockRepo.EXPECT().GetQuotation(gomock.Any(), latestID).Return(latestQuote, nil)
 
 	conf := &config.Config{
 		ResponseDelay: 5 * time.Second,
 		Quotations:    []string{"EUR", "MXN", "USD"},
-		QuoteAPI: struct {
-			URL string `yaml:"url"`
-		}{
-			URL: "https://api.frankfurter.app/latest?from=%s&to=%s"},
 	}
+	conf.QuoteAPI.URL = upstreamURL(t, `{"base":"%s","rates":{"%s":%s}}`, from, to, latestQuote.Rate)
 	q := &quotation.Quotation{
 		Ctx:    context.Background(),
 		Repo:   mockRepo,
@@ -266,8 +267,8 @@ func TestHTTPServer_GetLatestQuote_quote_in_database(t *testing.T) {
 	}
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().GetQuotePairs().Return([][]string{{"USD", "MXN"}}, nil)
-	mockRepo.EXPECT().GetLastUpdated(from, to).Return(latestQuote, nil)
+	mockRepo.EXPECT().GetQuotePairs(gomock.Any()).Return([][]string{{"USD", "MXN"}}, nil)
+	mockRepo.EXPECT().GetLastUpdated(gomock.Any(), from, to).Return(latestQuote, nil)
 
 	conf := &config.Config{
 		ResponseDelay: 5 * time.Second,
@@ -321,3 +322,185 @@ func TestHTTPServer_GetLatestQuote_quote_in_database(t *testing.T) {
 			latestQuote.Rate, got.Rate, latestQuote.Timestamp, got.LastUpdated)
 	}
 }
+
+func TestHTTPServer_GetStats(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now().UTC()
+	quotes := []*models.Quote{
+		{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD", Timestamp: now.Add(-2 * time.Hour), Rate: decimal.NewFromFloat(1.05)},
+		{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD", Timestamp: now.Add(-time.Hour), Rate: decimal.NewFromFloat(1.10)},
+	}
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetQuotationsSince(gomock.Any(), "EUR", "USD", gomock.Any()).Return(quotes, nil).Times(1)
+
+	conf := &config.Config{
+		Quotations: []string{"EUR", "MXN", "USD"},
+	}
+	q := quotation.NewQuotation(context.Background(), mockRepo, conf)
+	q.StatsCache = stats.NewCache(time.Minute)
+	srv := NewServer(conf, *q)
+	testServer := httptest.NewServer(http.HandlerFunc(srv.GetStats))
+	defer testServer.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(testServer.URL + "/stats?quote=EUR/USD&window=7d")
+		if err != nil {
+			t.Fatalf("Error getting stats: %v", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected status code: %v", resp.StatusCode)
+		}
+
+		got := &models.Stats{}
+		err = json.NewDecoder(resp.Body).Decode(got)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Errorf("Error unmarshalling response body: %v", err)
+		}
+
+		if got.Window != "7d" || got.Points != 2 || !got.Max.Equal(decimal.NewFromFloat(1.10)) {
+			t.Errorf("Unexpected stats: %+v", got)
+		}
+	}
+}
+
+func TestHTTPServer_GetStats_invalid_window(t *testing.T) {
+	conf := &config.Config{
+		Quotations: []string{"EUR", "USD"},
+	}
+	srv := NewServer(conf, quotation.Quotation{Config: conf})
+	testServer := httptest.NewServer(http.HandlerFunc(srv.GetStats))
+	defer testServer.Close()
+
+	resp, err := http.Get(testServer.URL + "/stats?quote=EUR/USD&window=week")
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code: %v", resp.StatusCode)
+	}
+}
+
+func TestHTTPServer_GetLatestQuote_base(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	timestamp := time.Now().UTC()
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetLatestQuotes(gomock.Any(), "EUR").Return([]*models.Quote{
+		{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD", Timestamp: timestamp, Rate: decimal.NewFromFloat(1.07)},
+		{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "MXN", Timestamp: timestamp, Rate: decimal.NewFromFloat(17.8)},
+	}, nil)
+
+	conf := &config.Config{
+		Quotations: []string{"EUR", "MXN", "USD"},
+	}
+	q := &quotation.Quotation{
+		Ctx:    context.Background(),
+		Repo:   mockRepo,
+		Config: conf,
+	}
+	srv := NewServer(conf, *q)
+	testServer := httptest.NewServer(http.HandlerFunc(srv.GetLatestQuote))
+	defer testServer.Close()
+
+	resp, err := http.Get(testServer.URL + "/latest?base=EUR")
+	if err != nil {
+		t.Fatalf("Error getting quote: %v", err)
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code: %v", resp.StatusCode)
+	}
+
+	got := &models.BaseLatestResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(got); err != nil {
+		t.Fatalf("Error unmarshalling response body: %v", err)
+	}
+
+	if got.Base != "EUR" || len(got.Rates) != 2 || !got.Rates["MXN"].Rate.Equal(decimal.NewFromFloat(17.8)) ||
+		!got.Rates["USD"].LastUpdated.Equal(timestamp) {
+		t.Errorf("Unexpected response: %+v", got)
+	}
+}
+
+// expectTx runs WithTx callbacks against mockRepo itself.
+func expectTx(mockRepo *mock_repository.MockRepository) {
+	mockRepo.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, fn func(repository.Repository) error) error {
+			return fn(mockRepo)
+		})
+}
+
+func TestHTTPServer_readiness(t *testing.T) {
+	srv := NewServer(&config.Config{}, quotation.Quotation{})
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", srv.Readyz)
+	mux.Handle("/latest", api)
+	handler := srv.readiness(mux)
+
+	tests := []struct {
+		ready bool
+		path  string
+		want  int
+	}{
+		{ready: false, path: "/latest", want: http.StatusServiceUnavailable},
+		{ready: false, path: "/readyz", want: http.StatusServiceUnavailable},
+		{ready: true, path: "/latest", want: http.StatusOK},
+		{ready: true, path: "/readyz", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		srv.SetReady(tt.ready)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("ready=%v GET %s = %d, want %d", tt.ready, tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHTTPServer_StartServer_auth_without_keys(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	conf := &config.Config{}
+	conf.Auth.Enabled = true
+	conf.Server.Port = "127.0.0.1:0"
+	srv := NewServer(conf, quotation.Quotation{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.StartServer(ctx); err == nil {
+		t.Fatal("StartServer() with auth enabled and no API key store: want error")
+	}
+}
+
+// upstreamURL serves body, formatted with args, as the quote API and returns
+// its URL template.
+func upstreamURL(t *testing.T, body string, args ...any) string {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, body, args...)
+	}))
+	t.Cleanup(upstream.Close)
+
+	return upstream.URL + "/latest?from=%s&to=%s"
+}
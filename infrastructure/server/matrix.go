@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/mashmorsik/logger"
+	"net/http"
+)
+
+func (s *HTTPServer) getLatestForBase(w http.ResponseWriter, r *http.Request, base string) {
+	if err := s.validateCurrency(base); err != nil {
+		logger.Errf("invalid base currency: %s", base)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.Quote.GetLatestForBase(r.Context(), base)
+	if err != nil {
+		logger.Errf("fail to GetLatestForBase for %s: %v", base, err)
+		http.Error(w, "fail to GetLatestForBase", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *HTTPServer) GetMatrix(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.Quote.GetMatrix(r.Context())
+	if err != nil {
+		logger.Errf("fail to GetMatrix: %v", err)
+		http.Error(w, "fail to GetMatrix", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
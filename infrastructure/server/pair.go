@@ -0,0 +1,155 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/currency"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"net/http"
+)
+
+func pathPair(r *http.Request) string {
+	vars := mux.Vars(r)
+	return fmt.Sprintf("%s/%s", vars["base"], vars["target"])
+}
+
+func (s *HTTPServer) ListPairs(w http.ResponseWriter, r *http.Request) {
+	pairs, err := s.Quote.GetPairs(r.Context())
+	if err != nil {
+		logger.Errf("fail to GetPairs: %v", err)
+		http.Error(w, "fail to GetPairs", http.StatusInternalServerError)
+		return
+	}
+	if pairs == nil {
+		pairs = []*models.TrackedPair{}
+	}
+
+	writeJSON(w, http.StatusOK, pairs)
+}
+
+func (s *HTTPServer) AddPair(w http.ResponseWriter, r *http.Request) {
+	var req models.TrackedPairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to parse JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.validateQuote(req.Quote); err != nil {
+		logger.Errf("invalid quotePair: %s", req.Quote)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var schedule string
+	if req.Schedule != nil {
+		schedule = *req.Schedule
+		if err := validateSchedule(schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, to := currency.SeparateCurrency(req.Quote)
+
+	pair, created, err := s.Quote.AddPair(r.Context(), from, to, req.Enabled == nil || *req.Enabled, schedule)
+	if err != nil {
+		logger.Errf("fail to AddPair for %s: %v", req.Quote, err)
+		http.Error(w, "fail to AddPair", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, pair)
+}
+
+func (s *HTTPServer) GetPair(w http.ResponseWriter, r *http.Request) {
+	qPair := pathPair(r)
+	if err := s.validateQuote(qPair); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to := currency.SeparateCurrency(qPair)
+
+	pair, err := s.Quote.GetPair(r.Context(), from, to)
+	if err != nil {
+		logger.Errf("fail to GetPair for %s: %v", qPair, err)
+		http.Error(w, "fail to GetPair", http.StatusInternalServerError)
+		return
+	}
+	if pair == nil {
+		http.Error(w, "Pair is not tracked", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pair)
+}
+
+func (s *HTTPServer) UpdatePair(w http.ResponseWriter, r *http.Request) {
+	qPair := pathPair(r)
+	if err := s.validateQuote(qPair); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req models.TrackedPairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to parse JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Enabled == nil && req.Schedule == nil {
+		http.Error(w, "enabled or schedule is required", http.StatusBadRequest)
+		return
+	}
+	if req.Schedule != nil {
+		if err := validateSchedule(*req.Schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, to := currency.SeparateCurrency(qPair)
+
+	if err := s.Quote.UpdatePair(r.Context(), from, to, req.Enabled, req.Schedule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Pair is not tracked", http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to update pair %s: %v", qPair, err)
+		http.Error(w, "fail to update pair", http.StatusInternalServerError)
+		return
+	}
+
+	// read back from the primary, a replica may not have the update yet
+	s.GetPair(w, r.WithContext(data.WithPrimary(r.Context())))
+}
+
+func (s *HTTPServer) DeletePair(w http.ResponseWriter, r *http.Request) {
+	qPair := pathPair(r)
+	if err := s.validateQuote(qPair); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to := currency.SeparateCurrency(qPair)
+
+	if err := s.Quote.DeletePair(r.Context(), from, to); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Pair is not tracked", http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to DeletePair for %s: %v", qPair, err)
+		http.Error(w, "fail to DeletePair", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
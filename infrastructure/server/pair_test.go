@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/internal/quotation"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	errs "github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// primaryCtx matches contexts whose reads go to the primary.
+type primaryCtx struct{}
+
+func (primaryCtx) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && data.IsPrimary(ctx)
+}
+
+func (primaryCtx) String() string {
+	return "is a context reading from the primary"
+}
+
+func TestHTTPServer_pairs(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pair := &models.TrackedPair{ID: 1, BaseCurrency: "EUR", TargetCurrency: "MXN", Enabled: true, CreatedAt: time.Now()}
+
+	mockRepo := mock_repository.NewMockRepository(ctrl)
+	// adds and updates each run in a transaction
+	for i := 0; i < 5; i++ {
+		expectTx(mockRepo)
+	}
+	mockRepo.EXPECT().LockQuotePair(gomock.Any(), "EUR", "MXN").Return(nil)
+	mockRepo.EXPECT().LockQuotePair(gomock.Any(), "USD", "EUR").Return(nil)
+	gomock.InOrder(
+		mockRepo.EXPECT().GetTrackedPair(gomock.Any(), "EUR", "MXN").Return(nil, nil),
+		mockRepo.EXPECT().AddQuotePair(gomock.Any(), "EUR", "MXN").Return(true, nil),
+		mockRepo.EXPECT().GetTrackedPair(gomock.Any(), "EUR", "MXN").Return(pair, nil),
+	)
+	mockRepo.EXPECT().GetTrackedPair(gomock.Any(), "USD", "EUR").Return(pair, nil)
+	mockRepo.EXPECT().SetQuotePairEnabled(gomock.Any(), "USD", "EUR", false).Return(nil)
+	// the updated pair is read back from the primary
+	mockRepo.EXPECT().GetTrackedPair(primaryCtx{}, "USD", "EUR").Return(pair, nil)
+	mockRepo.EXPECT().SetQuotePairEnabled(gomock.Any(), "USD", "MXN", false).
+		Return(errs.WithMessage(sql.ErrNoRows, "quote pair not found"))
+	mockRepo.EXPECT().SetQuotePairSchedule(gomock.Any(), "MXN", "USD", "10m").
+		Return(errs.WithMessage(sql.ErrNoRows, "quote pair not found"))
+	mockRepo.EXPECT().DeleteQuotePair(gomock.Any(), "EUR", "MXN").Return(nil)
+
+	conf := &config.Config{
+		Quotations: []string{"EUR", "MXN", "USD"},
+	}
+	q := &quotation.Quotation{
+		Ctx:    context.Background(),
+		Repo:   mockRepo,
+		Config: conf,
+	}
+	srv := NewServer(conf, *q)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/pairs", srv.AddPair).Methods(http.MethodPost)
+	router.HandleFunc("/pairs/{base}/{target}", srv.UpdatePair).Methods(http.MethodPatch)
+	router.HandleFunc("/pairs/{base}/{target}", srv.DeletePair).Methods(http.MethodDelete)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "add_new", method: http.MethodPost, path: "/pairs", body: `{"quote":"EUR/MXN"}`, wantStatus: http.StatusCreated},
+		{name: "add_existing", method: http.MethodPost, path: "/pairs", body: `{"quote":"USD/EUR"}`, wantStatus: http.StatusOK},
+		{name: "add_invalid", method: http.MethodPost, path: "/pairs", body: `{"quote":"EUR/EUR"}`, wantStatus: http.StatusBadRequest},
+		{name: "disable", method: http.MethodPatch, path: "/pairs/USD/EUR", body: `{"enabled":false}`, wantStatus: http.StatusOK},
+		{name: "disable_untracked", method: http.MethodPatch, path: "/pairs/USD/MXN", body: `{"enabled":false}`, wantStatus: http.StatusNotFound},
+		{name: "patch_without_fields", method: http.MethodPatch, path: "/pairs/USD/MXN", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "patch_invalid_schedule", method: http.MethodPatch, path: "/pairs/USD/MXN", body: `{"schedule":"often"}`, wantStatus: http.StatusBadRequest},
+		{name: "schedule_untracked", method: http.MethodPatch, path: "/pairs/MXN/USD", body: `{"schedule":"10m"}`, wantStatus: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, path: "/pairs/EUR/MXN", wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, testServer.URL+tt.path, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Error calling %s %s: %v", tt.method, tt.path, err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Unexpected status code: %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// TestHTTPServer_pairs_memory runs a full pair lifecycle against the
+// in-memory repository instead of mocked calls.
+func TestHTTPServer_pairs_memory(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"base":"EUR","rates":{"MXN":20.1}}`))
+	}))
+	defer upstream.Close()
+
+	conf := &config.Config{
+		Quotations: []string{"EUR", "MXN", "USD"},
+	}
+	conf.QuoteAPI.URL = upstream.URL + "/latest?from=%s&to=%s"
+	q := quotation.NewQuotation(context.Background(), repository.NewMemoryRepo(), conf)
+	srv := NewServer(conf, *q)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/update", srv.UpdateQuote).Methods(http.MethodPost)
+	router.HandleFunc("/pairs", srv.AddPair).Methods(http.MethodPost)
+	router.HandleFunc("/pairs/{base}/{target}", srv.GetPair).Methods(http.MethodGet)
+	router.HandleFunc("/pairs/{base}/{target}", srv.UpdatePair).Methods(http.MethodPatch)
+	router.HandleFunc("/pairs/{base}/{target}", srv.DeletePair).Methods(http.MethodDelete)
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	steps := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{method: http.MethodGet, path: "/pairs/EUR/MXN", wantStatus: http.StatusNotFound},
+		{method: http.MethodPost, path: "/pairs", body: `{"quote":"EUR/MXN","schedule":"10m"}`, wantStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/pairs", body: `{"quote":"EUR/MXN"}`, wantStatus: http.StatusOK},
+		{method: http.MethodPatch, path: "/pairs/EUR/MXN", body: `{"enabled":false}`, wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/pairs/EUR/MXN", wantStatus: http.StatusOK},
+		{method: http.MethodDelete, path: "/pairs/EUR/MXN", wantStatus: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/pairs/EUR/MXN", wantStatus: http.StatusNotFound},
+		// a removed pair is not tracked again by /update, only by POST /pairs
+		{method: http.MethodPost, path: "/update", body: `{"quote":"EUR/MXN"}`, wantStatus: http.StatusConflict},
+		{method: http.MethodGet, path: "/pairs/EUR/MXN", wantStatus: http.StatusNotFound},
+		{method: http.MethodPost, path: "/pairs", body: `{"quote":"EUR/MXN"}`, wantStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/update", body: `{"quote":"EUR/MXN"}`, wantStatus: http.StatusOK},
+	}
+	for _, st := range steps {
+		req, err := http.NewRequest(st.method, testServer.URL+st.path, bytes.NewBufferString(st.body))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error calling %s %s: %v", st.method, st.path, err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != st.wantStatus {
+			t.Fatalf("%s %s: unexpected status code: %v, want %v", st.method, st.path, resp.StatusCode, st.wantStatus)
+		}
+	}
+}
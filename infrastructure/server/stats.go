@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/internal/stats"
+	"github.com/mashmorsik/quotation/pkg/currency"
+	"net/http"
+)
+
+func (s *HTTPServer) GetStats(w http.ResponseWriter, r *http.Request) {
+	qPair := r.URL.Query().Get("quote")
+	if err := s.validateQuote(qPair); err != nil {
+		logger.Errf("invalid quotePair: %s", qPair)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+	d, err := parseWindow(window)
+	if err != nil {
+		logger.Errf("invalid window: %s", window)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to := currency.SeparateCurrency(qPair)
+
+	st, err := s.Quote.GetStats(r.Context(), from, to, d)
+	if err != nil {
+		if errors.Is(err, stats.ErrNoData) {
+			http.Error(w, fmt.Sprintf("No quotations for %s in the last %s", qPair, window), http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to GetStats for %s: %v", qPair, err)
+		http.Error(w, "fail to GetStats", http.StatusInternalServerError)
+		return
+	}
+
+	resp := *st
+	resp.Window = window
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		logger.Errf("failed to marshal JSON: %v", err)
+		http.Error(w, "Failed to marshal JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(jsonData)
+	if err != nil {
+		logger.Errf("failed to write response: %v", err)
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
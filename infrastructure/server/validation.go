@@ -2,9 +2,12 @@ package server
 
 import (
 	"errors"
+	cronSc "github.com/mashmorsik/quotation/cron"
 	"github.com/mashmorsik/quotation/pkg/currency"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func (s *HTTPServer) validateQuote(quote string) error {
@@ -31,3 +34,71 @@ func (s *HTTPServer) validateQuote(quote string) error {
 
 	return nil
 }
+
+func (s *HTTPServer) validateCurrency(cur string) error {
+	if cur == "" {
+		return errors.New("currency is required")
+	}
+
+	if !slices.Contains(s.Config.Quotations, cur) {
+		return errors.New("currency is invalid")
+	}
+
+	return nil
+}
+
+// maxWindowDays bounds stats windows to about ten years, well below where
+// the duration would overflow.
+const maxWindowDays = 3660
+
+// parseWindow accepts Go durations plus whole days ("7d") and weeks ("2w").
+func parseWindow(window string) (time.Duration, error) {
+	if window == "" {
+		return 0, errors.New("window is required")
+	}
+
+	var d time.Duration
+	switch unit := window[len(window)-1]; unit {
+	case 'd', 'w':
+		n, err := strconv.Atoi(window[:len(window)-1])
+		if err != nil {
+			return 0, errors.New("window is invalid")
+		}
+		if n <= 0 {
+			return 0, errors.New("window must be positive")
+		}
+		if unit == 'w' {
+			if n > maxWindowDays/7 {
+				return 0, errors.New("window is too long")
+			}
+			n *= 7
+		}
+		if n > maxWindowDays {
+			return 0, errors.New("window is too long")
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	default:
+		var err error
+		d, err = time.ParseDuration(window)
+		if err != nil {
+			return 0, errors.New("window is invalid")
+		}
+	}
+
+	if d <= 0 {
+		return 0, errors.New("window must be positive")
+	}
+	if d > maxWindowDays*24*time.Hour {
+		return 0, errors.New("window is too long")
+	}
+
+	return d, nil
+}
+
+// validateSchedule allows an empty schedule, which means the global one.
+func validateSchedule(schedule string) error {
+	if schedule == "" {
+		return nil
+	}
+	return cronSc.ValidateSchedule(schedule)
+}
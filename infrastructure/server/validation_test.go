@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/mashmorsik/quotation/config"
 	"testing"
+	"time"
 )
 
 func TestHTTPServer_validateQuote_errors(t *testing.T) {
@@ -44,3 +45,36 @@ func TestHTTPServer_validateQuote_errors(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "days", window: "7d", want: 7 * 24 * time.Hour},
+		{name: "weeks", window: "2w", want: 14 * 24 * time.Hour},
+		{name: "go_duration", window: "90m", want: 90 * time.Minute},
+		{name: "empty", window: "", wantErr: true},
+		{name: "garbage", window: "xd", wantErr: true},
+		{name: "negative", window: "-1d", wantErr: true},
+		{name: "zero", window: "0w", wantErr: true},
+		{name: "longest", window: "3660d", want: 3660 * 24 * time.Hour},
+		{name: "too_long", window: "3661d", wantErr: true},
+		{name: "overflow", window: "999999999w", wantErr: true},
+		{name: "too_long_duration", window: "100000h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWindow(tt.window)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseWindow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseWindow() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
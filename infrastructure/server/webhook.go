@@ -0,0 +1,146 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"net/http"
+)
+
+func (s *HTTPServer) ListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := s.Webhooks.GetEndpoints(r.Context())
+	if err != nil {
+		logger.Errf("fail to GetEndpoints: %v", err)
+		http.Error(w, "fail to GetEndpoints", http.StatusInternalServerError)
+		return
+	}
+	if endpoints == nil {
+		endpoints = []*models.WebhookEndpoint{}
+	}
+
+	writeJSON(w, http.StatusOK, endpoints)
+}
+
+func (s *HTTPServer) CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req models.WebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to parse JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Webhooks.ValidateURL(r.Context(), req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:     req.URL,
+		Secret:  req.Secret,
+		Events:  req.Events,
+		Enabled: req.Enabled == nil || *req.Enabled,
+	}
+
+	endpoint, err := s.Webhooks.CreateEndpoint(r.Context(), endpoint)
+	if err != nil {
+		logger.Errf("fail to CreateEndpoint for %s: %v", req.URL, err)
+		http.Error(w, "fail to CreateEndpoint", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, endpoint)
+}
+
+func (s *HTTPServer) GetWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := s.Webhooks.GetEndpoint(r.Context(), id)
+	if err != nil {
+		logger.Errf("fail to GetEndpoint %s: %v", id, err)
+		http.Error(w, "fail to GetEndpoint", http.StatusInternalServerError)
+		return
+	}
+	if endpoint == nil {
+		http.Error(w, "Webhook endpoint not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, endpoint)
+}
+
+// UpdateWebhookEndpoint enables or disables an endpoint. Re-enabling resets
+// the failure counter of an endpoint that was disabled automatically.
+func (s *HTTPServer) UpdateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.WebhookEndpointRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to parse JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Enabled == nil {
+		http.Error(w, "enabled is required", http.StatusBadRequest)
+		return
+	}
+
+	if err = s.Webhooks.SetEndpointEnabled(r.Context(), id, *req.Enabled); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Webhook endpoint not found", http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to SetEndpointEnabled %s: %v", id, err)
+		http.Error(w, "fail to SetEndpointEnabled", http.StatusInternalServerError)
+		return
+	}
+
+	s.GetWebhookEndpoint(w, r)
+}
+
+func (s *HTTPServer) DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err = s.Webhooks.DeleteEndpoint(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Webhook endpoint not found", http.StatusNotFound)
+			return
+		}
+		logger.Errf("fail to DeleteEndpoint %s: %v", id, err)
+		http.Error(w, "fail to DeleteEndpoint", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := s.Webhooks.GetDeliveries(r.Context(), id)
+	if err != nil {
+		logger.Errf("fail to GetDeliveries %s: %v", id, err)
+		http.Error(w, "fail to GetDeliveries", http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,196 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/internal/webhook"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	errs "github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"slices"
+	"time"
+)
+
+var comparators = []string{">", ">=", "<", "<="}
+
+var ErrInvalidComparator = errors.New("comparator must be one of >, >=, <, <=")
+
+type Alert struct {
+	Repo      repository.AlertRepository
+	QuoteRepo repository.Repository
+	Webhooks  *webhook.Webhook
+	Config    *config.Config
+}
+
+func NewAlert(repo repository.AlertRepository, quoteRepo repository.Repository, conf *config.Config) *Alert {
+	return &Alert{Repo: repo, QuoteRepo: quoteRepo, Config: conf}
+}
+
+func ValidateComparator(c string) error {
+	if !slices.Contains(comparators, c) {
+		return ErrInvalidComparator
+	}
+	return nil
+}
+
+// Matches reports whether value satisfies the rule's comparator and threshold.
+func Matches(rule *models.AlertRule, value decimal.Decimal) bool {
+	switch rule.Comparator {
+	case ">":
+		return value.GreaterThan(rule.Threshold)
+	case ">=":
+		return value.GreaterThanOrEqual(rule.Threshold)
+	case "<":
+		return value.LessThan(rule.Threshold)
+	case "<=":
+		return value.LessThanOrEqual(rule.Threshold)
+	default:
+		return false
+	}
+}
+
+func (a *Alert) CreateRule(ctx context.Context, rule *models.AlertRule) (*models.AlertRule, error) {
+	rule.ID = uuid.New()
+	rule.CreatedAt = time.Now().UTC()
+
+	if err := a.Repo.AddAlertRule(ctx, rule); err != nil {
+		return nil, errs.WithMessagef(err, "failed to AddAlertRule for %s/%s", rule.BaseCurrency, rule.TargetCurrency)
+	}
+
+	return rule, nil
+}
+
+func (a *Alert) GetRules(ctx context.Context) ([]*models.AlertRule, error) {
+	rules, err := a.Repo.GetAlertRules(ctx)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to GetAlertRules")
+	}
+	return rules, nil
+}
+
+func (a *Alert) GetRule(ctx context.Context, id uuid.UUID) (*models.AlertRule, error) {
+	rule, err := a.Repo.GetAlertRule(ctx, id)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetAlertRule, for: %v", id)
+	}
+	return rule, nil
+}
+
+func (a *Alert) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	if err := a.Repo.UpdateAlertRule(ctx, rule); err != nil {
+		return errs.WithMessagef(err, "failed to UpdateAlertRule, for: %v", rule.ID)
+	}
+	return nil
+}
+
+func (a *Alert) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	if err := a.Repo.DeleteAlertRule(ctx, id); err != nil {
+		return errs.WithMessagef(err, "failed to DeleteAlertRule, for: %v", id)
+	}
+	return nil
+}
+
+func (a *Alert) GetFirings(ctx context.Context, ruleID uuid.UUID) ([]*models.AlertFiring, error) {
+	firings, err := a.Repo.GetAlertFirings(ctx, ruleID)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetAlertFirings, for: %v", ruleID)
+	}
+	return firings, nil
+}
+
+// Evaluate checks every enabled rule for the quote's pair and records a
+// firing for each rule that starts matching with it, i.e. crosses its
+// threshold, and is not cooling down. A rule that keeps matching does not
+// fire again until a quote stops matching it.
+func (a *Alert) Evaluate(ctx context.Context, quote *models.Quote) ([]*models.AlertFiring, error) {
+	rules, err := a.Repo.GetAlertRulesForPair(ctx, quote.BaseCurrency, quote.TargetCurrency)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetAlertRulesForPair for %s/%s",
+			quote.BaseCurrency, quote.TargetCurrency)
+	}
+
+	var fired []*models.AlertFiring
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		value, ok, err := a.value(ctx, rule, quote)
+		if err != nil {
+			logger.Errf("fail to evaluate alert rule %s: %v", rule.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		matches := Matches(rule, value)
+		crossed, err := a.Repo.SetAlertRuleMatching(ctx, rule.ID, matches)
+		if err != nil {
+			logger.Errf("fail to SetAlertRuleMatching for rule %s: %v", rule.ID, err)
+			continue
+		}
+		if !matches || !crossed || coolingDown(rule, quote.Timestamp) {
+			continue
+		}
+
+		firing := &models.AlertFiring{
+			ID:      uuid.New(),
+			RuleID:  rule.ID,
+			QuoteID: quote.ID,
+			Value:   value,
+			FiredAt: quote.Timestamp,
+		}
+		recorded, err := a.Repo.AddAlertFiring(ctx, firing)
+		if err != nil {
+			logger.Errf("fail to AddAlertFiring for rule %s: %v", rule.ID, err)
+			continue
+		}
+		if recorded {
+			logger.Infof("alert rule %s fired for %s/%s: %s %s %s", rule.ID, quote.BaseCurrency,
+				quote.TargetCurrency, value, rule.Comparator, rule.Threshold)
+			fired = append(fired, firing)
+
+			if a.Webhooks != nil {
+				event := &models.AlertEvent{Rule: rule, Firing: firing, Quote: quote}
+				if err = a.Webhooks.Publish(ctx, webhook.EventAlertFired, event); err != nil {
+					logger.Errf("fail to publish %s for rule %s: %v", webhook.EventAlertFired, rule.ID, err)
+				}
+			}
+		}
+	}
+
+	return fired, nil
+}
+
+// value returns the quantity the rule compares against its threshold: the
+// rate itself, or the percent change over the rule's window. ok is false
+// when there is not enough history to compute a change.
+func (a *Alert) value(ctx context.Context, rule *models.AlertRule, quote *models.Quote) (decimal.Decimal, bool, error) {
+	window := time.Duration(rule.ChangeWindow)
+	if window <= 0 {
+		return quote.Rate, true, nil
+	}
+
+	quotes, err := a.QuoteRepo.GetQuotationsSince(ctx, quote.BaseCurrency, quote.TargetCurrency, quote.Timestamp.Add(-window))
+	if err != nil {
+		return decimal.Zero, false, errs.WithMessage(err, "failed to GetQuotationsSince")
+	}
+	if len(quotes) == 0 || quotes[0].ID == quote.ID || quotes[0].Rate.IsZero() {
+		return decimal.Zero, false, nil
+	}
+
+	base := quotes[0].Rate
+	return quote.Rate.Sub(base).Div(base).Mul(decimal.NewFromInt(100)), true, nil
+}
+
+func coolingDown(rule *models.AlertRule, at time.Time) bool {
+	if rule.LastFiredAt == nil {
+		return false
+	}
+	return rule.LastFiredAt.Add(time.Duration(rule.Cooldown)).After(at)
+}
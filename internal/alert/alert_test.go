@@ -0,0 +1,176 @@
+package alert
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	"github.com/shopspring/decimal"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		comparator string
+		value      float64
+		want       bool
+	}{
+		{">", 20.1, true},
+		{">", 20, false},
+		{">=", 20, true},
+		{"<", 19.9, true},
+		{"<", 20, false},
+		{"<=", 20, true},
+		{"==", 20, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.comparator, func(t *testing.T) {
+			rule := &models.AlertRule{Comparator: tt.comparator, Threshold: decimal.NewFromInt(20)}
+			if got := Matches(rule, decimal.NewFromFloat(tt.value)); got != tt.want {
+				t.Errorf("Matches(%v %s 20) = %v, want %v", tt.value, tt.comparator, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlert_Evaluate(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Now().UTC()
+	recently := now.Add(-time.Minute)
+
+	quote := &models.Quote{
+		ID:             uuid.New(),
+		BaseCurrency:   "EUR",
+		TargetCurrency: "MXN",
+		Timestamp:      now,
+		Rate:           decimal.NewFromFloat(20.5),
+	}
+
+	crossed := &models.AlertRule{ID: uuid.New(), Comparator: ">", Threshold: decimal.NewFromInt(20), Enabled: true}
+	notCrossed := &models.AlertRule{ID: uuid.New(), Comparator: "<", Threshold: decimal.NewFromInt(20), Enabled: true}
+	coolingDown := &models.AlertRule{ID: uuid.New(), Comparator: ">", Threshold: decimal.NewFromInt(20), Enabled: true,
+		Cooldown: models.Duration(time.Hour), LastFiredAt: &recently}
+	changed := &models.AlertRule{ID: uuid.New(), Comparator: ">=", Threshold: decimal.NewFromInt(2), Enabled: true,
+		ChangeWindow: models.Duration(24 * time.Hour)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alertRepo := mock_repository.NewMockAlertRepository(ctrl)
+	alertRepo.EXPECT().GetAlertRulesForPair(gomock.Any(), "EUR", "MXN").
+		Return([]*models.AlertRule{crossed, notCrossed, coolingDown, changed}, nil)
+	expectMatching(alertRepo)
+	alertRepo.EXPECT().AddAlertFiring(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, f *models.AlertFiring) (bool, error) {
+		if f.QuoteID != quote.ID {
+			t.Errorf("unexpected quote id: %v", f.QuoteID)
+		}
+		return true, nil
+	}).Times(2)
+
+	quoteRepo := mock_repository.NewMockRepository(ctrl)
+	quoteRepo.EXPECT().GetQuotationsSince(gomock.Any(), "EUR", "MXN", now.Add(-24*time.Hour)).Return([]*models.Quote{
+		{ID: uuid.New(), Timestamp: now.Add(-20 * time.Hour), Rate: decimal.NewFromInt(20)},
+		quote,
+	}, nil)
+
+	a := NewAlert(alertRepo, quoteRepo, &config.Config{})
+
+	fired, err := a.Evaluate(context.Background(), quote)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if len(fired) != 2 || fired[0].RuleID != crossed.ID || fired[1].RuleID != changed.ID {
+		t.Fatalf("Evaluate() fired = %+v, want rules %v and %v", fired, crossed.ID, changed.ID)
+	}
+	if !fired[1].Value.Equal(decimal.NewFromFloat(2.5)) {
+		t.Errorf("percent change = %v, want 2.5", fired[1].Value)
+	}
+}
+
+func TestAlert_Evaluate_deduplicated(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	quote := &models.Quote{
+		ID:             uuid.New(),
+		BaseCurrency:   "EUR",
+		TargetCurrency: "MXN",
+		Timestamp:      time.Now().UTC(),
+		Rate:           decimal.NewFromFloat(20.5),
+	}
+	rule := &models.AlertRule{ID: uuid.New(), Comparator: ">", Threshold: decimal.NewFromInt(20), Enabled: true}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alertRepo := mock_repository.NewMockAlertRepository(ctrl)
+	alertRepo.EXPECT().GetAlertRulesForPair(gomock.Any(), "EUR", "MXN").Return([]*models.AlertRule{rule}, nil)
+	expectMatching(alertRepo)
+	alertRepo.EXPECT().AddAlertFiring(gomock.Any(), gomock.Any()).Return(false, nil)
+
+	a := NewAlert(alertRepo, nil, &config.Config{})
+
+	fired, err := a.Evaluate(context.Background(), quote)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(fired) != 0 {
+		t.Errorf("Evaluate() fired = %+v, want none", fired)
+	}
+}
+
+func TestAlert_Evaluate_fires_on_crossing(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	rule := &models.AlertRule{ID: uuid.New(), Comparator: ">", Threshold: decimal.NewFromInt(20), Enabled: true}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	alertRepo := mock_repository.NewMockAlertRepository(ctrl)
+	alertRepo.EXPECT().GetAlertRulesForPair(gomock.Any(), "EUR", "MXN").Return([]*models.AlertRule{rule}, nil).AnyTimes()
+	expectMatching(alertRepo)
+	alertRepo.EXPECT().AddAlertFiring(gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+
+	a := NewAlert(alertRepo, nil, &config.Config{})
+
+	now := time.Now().UTC()
+	var fired []string
+	for i, rate := range []float64{19.5, 20.5, 20.7, 21, 19.8, 20.2, 20.4} {
+		quote := &models.Quote{
+			ID:             uuid.New(),
+			BaseCurrency:   "EUR",
+			TargetCurrency: "MXN",
+			Timestamp:      now.Add(time.Duration(i) * 2 * time.Minute),
+			Rate:           decimal.NewFromFloat(rate),
+		}
+
+		firings, err := a.Evaluate(context.Background(), quote)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		for _, f := range firings {
+			fired = append(fired, f.Value.String())
+		}
+	}
+
+	if want := []string{"20.5", "20.2"}; !slices.Equal(fired, want) {
+		t.Errorf("fired at %v, want %v", fired, want)
+	}
+}
+
+// expectMatching keeps the matching state of rules like AlertRepo does.
+func expectMatching(repo *mock_repository.MockAlertRepository) {
+	state := make(map[uuid.UUID]bool)
+	repo.EXPECT().SetAlertRuleMatching(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id uuid.UUID, matching bool) (bool, error) {
+		changed := state[id] != matching
+		state[id] = matching
+		return changed, nil
+	}).AnyTimes()
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	errs "github.com/pkg/errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	keyPrefix    = "qk_"
+	prefixLength = 8
+)
+
+var (
+	ErrMissingKey    = errors.New("api key is required")
+	ErrInvalidKey    = errors.New("api key is invalid")
+	ErrQuotaExceeded = errors.New("daily request quota exceeded")
+	ErrUnknownScope  = errors.New("unknown scope")
+)
+
+type Auth struct {
+	Repo   repository.APIKeyRepository
+	Config *config.Config
+	now    func() time.Time
+}
+
+func NewAuth(repo repository.APIKeyRepository, conf *config.Config) *Auth {
+	return &Auth{Repo: repo, Config: conf, now: time.Now}
+}
+
+// HashKey returns the hex SHA-256 of a key. Keys carry 256 bits of
+// randomness, so a plain hash is enough to make the stored value useless.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new plain-text key and its display prefix.
+func GenerateKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errs.WithMessage(err, "failed to generate api key")
+	}
+
+	key := keyPrefix + hex.EncodeToString(b)
+	return key, key[:len(keyPrefix)+prefixLength], nil
+}
+
+// ValidateScopes checks that every scope is known and that at least one is
+// given.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errs.New("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			return errs.WithMessagef(ErrUnknownScope, "%q, expected one of %v", scope, models.Scopes)
+		}
+	}
+
+	return nil
+}
+
+// CreateKey stores a new key and returns it with its plain-text value,
+// which is not recoverable afterwards.
+func (a *Auth) CreateKey(ctx context.Context, name string, dailyQuota int, scopes []string) (*models.APIKey, string, error) {
+	if err := ValidateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	plain, prefix, err := GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	k := &models.APIKey{
+		ID:         uuid.New(),
+		Name:       name,
+		Prefix:     prefix,
+		KeyHash:    HashKey(plain),
+		DailyQuota: dailyQuota,
+		Scopes:     scopes,
+		CreatedAt:  a.now().UTC(),
+	}
+	if err = a.Repo.AddAPIKey(ctx, k); err != nil {
+		return nil, "", errs.WithMessagef(err, "failed to AddAPIKey for %s", name)
+	}
+
+	return k, plain, nil
+}
+
+func (a *Auth) GetKeys(ctx context.Context) ([]*models.APIKey, error) {
+	keys, err := a.Repo.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to GetAPIKeys")
+	}
+	return keys, nil
+}
+
+func (a *Auth) RevokeKey(ctx context.Context, id uuid.UUID) error {
+	if err := a.Repo.RevokeAPIKey(ctx, id); err != nil {
+		return errs.WithMessagef(err, "failed to RevokeAPIKey, for: %v", id)
+	}
+	return nil
+}
+
+// GetUsage returns the daily request counters of a key for the last days.
+func (a *Auth) GetUsage(ctx context.Context, id uuid.UUID, days int) ([]*models.APIKeyUsage, error) {
+	since := today(a.now()).AddDate(0, 0, -(days - 1))
+
+	usage, err := a.Repo.GetAPIKeyUsage(ctx, id, since)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetAPIKeyUsage, for: %v", id)
+	}
+	return usage, nil
+}
+
+// Authenticate resolves a plain-text key. The request is not counted yet,
+// see CountUsage.
+func (a *Auth) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	if key == "" {
+		return nil, ErrMissingKey
+	}
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+
+	k, err := a.Repo.GetAPIKeyByHash(ctx, HashKey(key))
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to GetAPIKeyByHash")
+	}
+	if k == nil || k.RevokedAt != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return k, nil
+}
+
+// CountUsage counts a request against the key's daily quota. It returns the
+// number of requests used today.
+func (a *Auth) CountUsage(ctx context.Context, k *models.APIKey) (int64, error) {
+	used, allowed, err := a.Repo.IncrementAPIKeyUsage(ctx, k.ID, today(a.now()), k.DailyQuota)
+	if err != nil {
+		return 0, errs.WithMessagef(err, "failed to IncrementAPIKeyUsage for %s", k.Prefix)
+	}
+	if !allowed {
+		return used, ErrQuotaExceeded
+	}
+
+	return used, nil
+}
+
+func today(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+type ctxKey struct{}
+
+// KeyFromContext returns the API key that authenticated the request, if any.
+func KeyFromContext(ctx context.Context) *models.APIKey {
+	k, _ := ctx.Value(ctxKey{}).(*models.APIKey)
+	return k
+}
+
+func withKey(ctx context.Context, k *models.APIKey) context.Context {
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+// extractKey reads the key from X-API-Key or an "Authorization: Bearer"
+// header.
+func extractKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+func (a *Auth) isPublic(path string) bool {
+	for _, p := range a.Config.Auth.PublicPaths {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests without a valid API key. Public paths from
+// the config are passed through. The key's daily quota is enforced by
+// Meter.
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.isPublic(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		k, err := a.Authenticate(r.Context(), extractKey(r))
+		switch {
+		case errors.Is(err, ErrMissingKey), errors.Is(err, ErrInvalidKey):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="quotation"`)
+			WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		case err != nil:
+			logger.Errf("fail to authenticate request: %v", err)
+			WriteError(w, http.StatusInternalServerError, "failed to authenticate request")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withKey(r.Context(), k)))
+	})
+}
+
+// Meter counts the request against the daily quota of its key and rejects
+// it once the quota is used up. It must wrap the handler itself, inside
+// Require and the rate limit, so requests they reject are not counted.
+func (a *Auth) Meter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := KeyFromContext(r.Context())
+		if k == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		used, err := a.CountUsage(r.Context(), k)
+		switch {
+		case errors.Is(err, ErrQuotaExceeded):
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(k.DailyQuota))
+			w.Header().Set("X-Quota-Remaining", "0")
+			WriteError(w, http.StatusTooManyRequests, err.Error())
+			return
+		case err != nil:
+			logger.Errf("fail to count request of api key %s: %v", k.Prefix, err)
+			WriteError(w, http.StatusInternalServerError, "failed to count request")
+			return
+		}
+
+		if k.DailyQuota > 0 {
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(k.DailyQuota))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(int64(k.DailyQuota)-used, 0), 10))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Require returns a middleware that only lets through requests whose key
+// was granted scope. It must run after Middleware.
+func (a *Auth) Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := KeyFromContext(r.Context())
+			if k == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="quotation"`)
+				WriteError(w, http.StatusUnauthorized, ErrMissingKey.Error())
+				return
+			}
+
+			if !k.HasScope(scope) {
+				WriteError(w, http.StatusForbidden, fmt.Sprintf("api key is missing scope %q", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteError writes a JSON error body with the given status.
+func WriteError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(models.ErrorResponse{Error: msg}); err != nil {
+		logger.Errf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuth_Middleware(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const plain = "qk_0123456789abcdef"
+	now := time.Date(2024, 5, 10, 15, 0, 0, 0, time.UTC)
+	revokedAt := now.Add(-time.Hour)
+
+	active := &models.APIKey{ID: uuid.New(), Name: "active", DailyQuota: 10, Scopes: []string{models.ScopeQuotesRead}}
+	revoked := &models.APIKey{ID: uuid.New(), Name: "revoked", RevokedAt: &revokedAt}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		setup      func(repo *mock_repository.MockAPIKeyRepository)
+		wantStatus int
+	}{
+		{
+			name:       "public path",
+			path:       "/swagger",
+			setup:      func(repo *mock_repository.MockAPIKeyRepository) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing key",
+			path:       "/latest",
+			setup:      func(repo *mock_repository.MockAPIKeyRepository) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed key",
+			path:       "/latest",
+			header:     http.Header{APIKeyHeader: {"nope"}},
+			setup:      func(repo *mock_repository.MockAPIKeyRepository) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown key",
+			path:   "/latest",
+			header: http.Header{APIKeyHeader: {plain}},
+			setup: func(repo *mock_repository.MockAPIKeyRepository) {
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), HashKey(plain)).Return(nil, nil)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "revoked key",
+			path:   "/latest",
+			header: http.Header{APIKeyHeader: {plain}},
+			setup: func(repo *mock_repository.MockAPIKeyRepository) {
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), HashKey(plain)).Return(revoked, nil)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "bearer key",
+			path:   "/latest",
+			header: http.Header{"Authorization": {"Bearer " + plain}},
+			setup: func(repo *mock_repository.MockAPIKeyRepository) {
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), HashKey(plain)).Return(active, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_repository.NewMockAPIKeyRepository(ctrl)
+			tt.setup(repo)
+
+			conf := &config.Config{}
+			conf.Auth.PublicPaths = []string{"/swagger"}
+
+			a := NewAuth(repo, conf)
+			a.now = func() time.Time { return now }
+
+			var gotKey *models.APIKey
+			h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotKey = KeyFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v[0])
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code != http.StatusOK {
+				var body models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == "" {
+					t.Errorf("expected JSON error body, got %q", rec.Body.String())
+				}
+				return
+			}
+			if tt.header != nil && gotKey != active {
+				t.Errorf("key in context = %v, want %v", gotKey, active)
+			}
+		})
+	}
+}
+
+func TestAuth_Require(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	reader := &models.APIKey{ID: uuid.New(), Scopes: []string{models.ScopeQuotesRead}}
+	admin := &models.APIKey{ID: uuid.New(), Scopes: []string{models.ScopeAdmin}}
+
+	tests := []struct {
+		name       string
+		key        *models.APIKey
+		scope      string
+		wantStatus int
+	}{
+		{name: "no key", scope: models.ScopeQuotesRead, wantStatus: http.StatusUnauthorized},
+		{name: "granted", key: reader, scope: models.ScopeQuotesRead, wantStatus: http.StatusOK},
+		{name: "missing scope", key: reader, scope: models.ScopeQuotesWrite, wantStatus: http.StatusForbidden},
+		{name: "admin implies all", key: admin, scope: models.ScopeQuotesWrite, wantStatus: http.StatusOK},
+	}
+
+	a := NewAuth(nil, &config.Config{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := a.Require(tt.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodPost, "/update", nil)
+			if tt.key != nil {
+				req = req.WithContext(withKey(req.Context(), tt.key))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code != http.StatusOK {
+				var body models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error == "" {
+					t.Errorf("expected JSON error body, got %q", rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAuth_Meter(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 5, 10, 15, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	reader := &models.APIKey{ID: uuid.New(), DailyQuota: 10, Scopes: []string{models.ScopeQuotesRead}}
+	writer := &models.APIKey{ID: uuid.New(), DailyQuota: 10, Scopes: []string{models.ScopeQuotesWrite}}
+	unlimited := &models.APIKey{ID: uuid.New(), Scopes: []string{models.ScopeQuotesRead}}
+
+	tests := []struct {
+		name       string
+		key        *models.APIKey
+		setup      func(repo *mock_repository.MockAPIKeyRepository)
+		wantStatus int
+		wantQuota  string
+	}{
+		{
+			name:       "no key",
+			setup:      func(repo *mock_repository.MockAPIKeyRepository) {},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "within quota",
+			key:  reader,
+			setup: func(repo *mock_repository.MockAPIKeyRepository) {
+				repo.EXPECT().IncrementAPIKeyUsage(gomock.Any(), reader.ID, day, 10).Return(int64(4), true, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantQuota:  "6",
+		},
+		{
+			name: "quota exceeded",
+			key:  reader,
+			setup: func(repo *mock_repository.MockAPIKeyRepository) {
+				repo.EXPECT().IncrementAPIKeyUsage(gomock.Any(), reader.ID, day, 10).Return(int64(10), false, nil)
+			},
+			wantStatus: http.StatusTooManyRequests,
+			wantQuota:  "0",
+		},
+		{
+			name: "unlimited",
+			key:  unlimited,
+			setup: func(repo *mock_repository.MockAPIKeyRepository) {
+				repo.EXPECT().IncrementAPIKeyUsage(gomock.Any(), unlimited.ID, day, 0).Return(int64(40), true, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			// rejected by Require, so not counted
+			name:       "missing scope",
+			key:        writer,
+			setup:      func(repo *mock_repository.MockAPIKeyRepository) {},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := mock_repository.NewMockAPIKeyRepository(ctrl)
+			tt.setup(repo)
+
+			a := NewAuth(repo, &config.Config{})
+			a.now = func() time.Time { return now }
+
+			h := a.Require(models.ScopeQuotesRead)(a.Meter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+			req := httptest.NewRequest(http.MethodGet, "/latest", nil)
+			if tt.key != nil {
+				req = req.WithContext(withKey(req.Context(), tt.key))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Quota-Remaining"); got != tt.wantQuota {
+				t.Errorf("X-Quota-Remaining = %q, want %q", got, tt.wantQuota)
+			}
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	if err := ValidateScopes([]string{models.ScopeQuotesRead, models.ScopeAdmin}); err != nil {
+		t.Errorf("ValidateScopes() error = %v", err)
+	}
+	if err := ValidateScopes(nil); err == nil {
+		t.Error("ValidateScopes(nil) expected error")
+	}
+	if err := ValidateScopes([]string{"quotes:delete"}); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("ValidateScopes() error = %v, want ErrUnknownScope", err)
+	}
+}
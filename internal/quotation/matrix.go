@@ -0,0 +1,74 @@
+package quotation
+
+import (
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+type pairKey struct {
+	from, to string
+}
+
+// BuildMatrix computes the cross-rate table among currencies from the latest
+// quote of every stored pair. A cell is taken from the direct quote, the
+// inverse of the opposite quote, or a product of two legs through a third
+// currency, in that order of preference. Cells that cannot be derived are nil.
+// LastUpdated of a derived cell is the oldest timestamp among its legs.
+func BuildMatrix(currencies []string, latest []*models.Quote) *models.MatrixResponse {
+	quotes := make(map[pairKey]*models.Quote, len(latest))
+	for _, q := range latest {
+		quotes[pairKey{q.BaseCurrency, q.TargetCurrency}] = q
+	}
+
+	one := decimal.NewFromInt(1)
+	leg := func(from, to string) *models.MatrixCell {
+		if q, ok := quotes[pairKey{from, to}]; ok {
+			return &models.MatrixCell{Rate: q.Rate, Source: models.MatrixSourceDirect, LastUpdated: q.Timestamp}
+		}
+		if q, ok := quotes[pairKey{to, from}]; ok && !q.Rate.IsZero() {
+			return &models.MatrixCell{Rate: one.Div(q.Rate), Source: models.MatrixSourceInverse, LastUpdated: q.Timestamp}
+		}
+		return nil
+	}
+
+	resp := &models.MatrixResponse{
+		Currencies: currencies,
+		Rates:      make(map[string]map[string]*models.MatrixCell, len(currencies)),
+	}
+
+	for _, from := range currencies {
+		row := make(map[string]*models.MatrixCell, len(currencies))
+		for _, to := range currencies {
+			if from == to {
+				continue
+			}
+
+			cell := leg(from, to)
+			if cell == nil {
+				for _, via := range currencies {
+					if via == from || via == to {
+						continue
+					}
+					first, second := leg(from, via), leg(via, to)
+					if first == nil || second == nil {
+						continue
+					}
+					cell = &models.MatrixCell{
+						Rate:        first.Rate.Mul(second.Rate),
+						Source:      models.MatrixSourceCross,
+						Via:         via,
+						LastUpdated: first.LastUpdated,
+					}
+					if second.LastUpdated.Before(cell.LastUpdated) {
+						cell.LastUpdated = second.LastUpdated
+					}
+					break
+				}
+			}
+			row[to] = cell
+		}
+		resp.Rates[from] = row
+	}
+
+	return resp
+}
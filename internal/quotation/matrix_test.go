@@ -0,0 +1,53 @@
+package quotation
+
+import (
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/shopspring/decimal"
+	"testing"
+	"time"
+)
+
+func TestBuildMatrix(t *testing.T) {
+	older := time.Date(2024, 4, 11, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	latest := []*models.Quote{
+		{BaseCurrency: "EUR", TargetCurrency: "USD", Rate: decimal.NewFromFloat(1.25), Timestamp: newer},
+		{BaseCurrency: "USD", TargetCurrency: "MXN", Rate: decimal.NewFromInt(16), Timestamp: older},
+	}
+
+	got := BuildMatrix([]string{"EUR", "USD", "MXN", "JPY"}, latest)
+
+	tests := []struct {
+		from, to    string
+		wantRate    string
+		wantSource  string
+		wantUpdated time.Time
+	}{
+		{"EUR", "USD", "1.25", models.MatrixSourceDirect, newer},
+		{"USD", "EUR", "0.8", models.MatrixSourceInverse, newer},
+		{"USD", "MXN", "16", models.MatrixSourceDirect, older},
+		{"EUR", "MXN", "20", models.MatrixSourceCross, older},
+		{"MXN", "EUR", "0.05", models.MatrixSourceCross, older},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"/"+tt.to, func(t *testing.T) {
+			cell := got.Rates[tt.from][tt.to]
+			if cell == nil {
+				t.Fatalf("missing cell")
+			}
+			if !cell.Rate.Equal(decimal.RequireFromString(tt.wantRate)) || cell.Source != tt.wantSource ||
+				!cell.LastUpdated.Equal(tt.wantUpdated) {
+				t.Errorf("cell = %+v, want rate %s, source %s, updated %v", cell, tt.wantRate, tt.wantSource,
+					tt.wantUpdated)
+			}
+		})
+	}
+
+	if cell, ok := got.Rates["EUR"]["JPY"]; !ok || cell != nil {
+		t.Errorf("EUR/JPY = %+v, %v, want nil cell", cell, ok)
+	}
+	if _, ok := got.Rates["EUR"]["EUR"]; ok {
+		t.Errorf("diagonal must be omitted")
+	}
+}
@@ -0,0 +1,121 @@
+package quotation
+
+import (
+	"context"
+	"errors"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	errs "github.com/pkg/errors"
+)
+
+func (q *Quotation) GetPairs(ctx context.Context) ([]*models.TrackedPair, error) {
+	pairs, err := q.Repo.GetTrackedPairs(ctx)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to GetTrackedPairs")
+	}
+	return pairs, nil
+}
+
+func (q *Quotation) GetPair(ctx context.Context, from, to string) (*models.TrackedPair, error) {
+	pair, err := q.Repo.GetTrackedPair(ctx, from, to)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetTrackedPair for %s/%s", from, to)
+	}
+	return pair, nil
+}
+
+// AddPair starts tracking a pair, also one removed with DeletePair. It
+// reports whether the pair was newly added; an existing pair is returned
+// unchanged. The pair is added with its settings in one transaction.
+func (q *Quotation) AddPair(ctx context.Context, from, to string, enabled bool, schedule string) (*models.TrackedPair, bool, error) {
+	var existing *models.TrackedPair
+	err := q.Repo.WithTx(ctx, func(repo repository.Repository) error {
+		if err := repo.LockQuotePair(ctx, from, to); err != nil {
+			return errs.WithMessagef(err, "failed to LockQuotePair for %s/%s", from, to)
+		}
+
+		pair, err := repo.GetTrackedPair(ctx, from, to)
+		if err != nil {
+			return errs.WithMessagef(err, "failed to GetTrackedPair for %s/%s", from, to)
+		}
+		if pair != nil {
+			existing = pair
+			return nil
+		}
+
+		_, err = repo.AddQuotePair(ctx, from, to)
+		if errors.Is(err, repository.ErrQuotePairRemoved) {
+			err = repo.RestoreQuotePair(ctx, from, to)
+		}
+		if err != nil {
+			return errs.WithMessagef(err, "failed to AddQuotePair for %s/%s", from, to)
+		}
+
+		if !enabled {
+			if err = setPairEnabled(ctx, repo, from, to, false); err != nil {
+				return err
+			}
+		}
+		if schedule != "" {
+			if err = setPairSchedule(ctx, repo, from, to, schedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	// read back from the primary, a replica may not have the new row yet
+	pair, err := q.GetPair(data.WithPrimary(ctx), from, to)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return pair, true, nil
+}
+
+// UpdatePair changes whether a pair is refreshed and, when schedule is set,
+// how often, in one transaction. The scheduler picks the change up on its
+// next reload.
+func (q *Quotation) UpdatePair(ctx context.Context, from, to string, enabled *bool, schedule *string) error {
+	return q.Repo.WithTx(ctx, func(repo repository.Repository) error {
+		if enabled != nil {
+			if err := setPairEnabled(ctx, repo, from, to, *enabled); err != nil {
+				return err
+			}
+		}
+		if schedule != nil {
+			if err := setPairSchedule(ctx, repo, from, to, *schedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func setPairEnabled(ctx context.Context, repo repository.Repository, from, to string, enabled bool) error {
+	if err := repo.SetQuotePairEnabled(ctx, from, to, enabled); err != nil {
+		return errs.WithMessagef(err, "failed to SetQuotePairEnabled for %s/%s", from, to)
+	}
+	return nil
+}
+
+func setPairSchedule(ctx context.Context, repo repository.Repository, from, to, schedule string) error {
+	if err := repo.SetQuotePairSchedule(ctx, from, to, schedule); err != nil {
+		return errs.WithMessagef(err, "failed to SetQuotePairSchedule for %s/%s", from, to)
+	}
+	return nil
+}
+
+func (q *Quotation) DeletePair(ctx context.Context, from, to string) error {
+	if err := q.Repo.DeleteQuotePair(ctx, from, to); err != nil {
+		return errs.WithMessagef(err, "failed to DeleteQuotePair for %s/%s", from, to)
+	}
+	return nil
+}
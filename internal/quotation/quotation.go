@@ -2,9 +2,14 @@ package quotation
 
 import (
 	"context"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
 	"github.com/mashmorsik/quotation/infrastructure/quote_api"
+	"github.com/mashmorsik/quotation/internal/stats"
+	"github.com/mashmorsik/quotation/internal/webhook"
 	"github.com/mashmorsik/quotation/pkg/models"
 	"github.com/mashmorsik/quotation/repository"
 	errs "github.com/pkg/errors"
@@ -12,16 +17,18 @@ import (
 )
 
 type Quotation struct {
-	Ctx    context.Context
-	Repo   repository.Repository
-	Config *config.Config
+	Ctx        context.Context
+	Repo       repository.Repository
+	Config     *config.Config
+	StatsCache *stats.Cache
+	Webhooks   *webhook.Webhook
 }
 
 func NewQuotation(ctx context.Context, repo repository.Repository, conf *config.Config) *Quotation {
-	return &Quotation{Ctx: ctx, Repo: repo, Config: conf}
+	return &Quotation{Ctx: ctx, Repo: repo, Config: conf, StatsCache: stats.NewCache(conf.Stats.CacheTTL)}
 }
 
-func (q *Quotation) GetQuoteAsync(from, to string) (uuid.UUID, error) {
+func (q *Quotation) GetQuoteAsync(ctx context.Context, from, to string) (uuid.UUID, error) {
 	quoteID := uuid.New()
 
 	rate, err := quote_api.GetQuote(from, to, q.Config)
@@ -37,31 +44,75 @@ func (q *Quotation) GetQuoteAsync(from, to string) (uuid.UUID, error) {
 		Rate:           rate,
 	}
 
-	err = q.Repo.AddQuotePair(quote.BaseCurrency, quote.TargetCurrency)
+	// the pair lock makes concurrent requests queue here, so only the first
+	// of them stores a quote and the rest reuse it
+	err = q.Repo.WithTx(ctx, func(repo repository.Repository) error {
+		if err := repo.LockQuotePair(ctx, from, to); err != nil {
+			return errs.WithMessagef(err, "failed to LockQuotePair, for: %v\n", quote)
+		}
+
+		if _, err := repo.AddQuotePair(ctx, quote.BaseCurrency, quote.TargetCurrency); err != nil {
+			return errs.WithMessagef(err, "failed to AddQuotePair, for: %v\n", quote)
+		}
+
+		quoteLatest, err := repo.GetLastUpdated(ctx, quote.BaseCurrency, quote.TargetCurrency)
+		if err != nil {
+			return errs.WithMessagef(err, "failed to GetLastUpdated, for: %v\n", quote)
+		}
+
+		now := time.Now().UTC()
+		if quoteLatest != nil {
+			if quoteLatest.Timestamp.Add(q.Config.ResponseDelay).After(now) {
+				quoteID = quoteLatest.ID
+				return nil
+			}
+		}
+
+		if err = repo.AddQuotation(ctx, quote); err != nil {
+			return errs.WithMessagef(err, "failed to AddQuotation, for: %v\n", quote)
+		}
+		return nil
+	})
 	if err != nil {
-		return uuid.UUID{}, errs.WithMessagef(err, "failed to AddQuotePair, for: %v\n", quote)
+		return uuid.UUID{}, err
 	}
 
-	quoteLatest, err := q.Repo.GetLastUpdated(quote.BaseCurrency, quote.TargetCurrency)
-	if err != nil {
-		return uuid.UUID{}, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v\n", quote)
+	return quoteID, nil
+}
+
+// UpdateQuote behaves like GetQuoteAsync and, when callbackURL is set, queues
+// a signed callback with the stored quote or the error.
+func (q *Quotation) UpdateQuote(ctx context.Context, from, to, callbackURL string) (uuid.UUID, error) {
+	quoteID, err := q.GetQuoteAsync(ctx, from, to)
+	if callbackURL == "" || q.Webhooks == nil {
+		return quoteID, err
 	}
 
-	now := time.Now().UTC()
-	if quoteLatest != nil {
-		if quoteLatest.Timestamp.Add(q.Config.ResponseDelay).After(now) {
-			return quoteLatest.ID, nil
+	callback := &models.UpdateCallback{Pair: fmt.Sprintf("%s/%s", from, to)}
+	event := webhook.EventQuoteUpdated
+	if err != nil {
+		event = webhook.EventQuoteFailed
+		callback.Status = models.CallbackStatusFailed
+		callback.Error = err.Error()
+	} else {
+		callback.QuoteID = &quoteID
+		callback.Status = models.CallbackStatusStored
+		quote, qErr := q.Repo.GetQuotation(data.WithPrimary(ctx), quoteID)
+		if qErr != nil {
+			logger.Errf("fail to GetQuotation %s for callback: %v", quoteID, qErr)
 		}
+		callback.Quote = quote
 	}
 
-	if err = q.Repo.AddQuotation(quote); err != nil {
-		return uuid.UUID{}, errs.WithMessagef(err, "failed to AddQuotation, for: %v\n", quote)
+	if sendErr := q.Webhooks.Send(ctx, callbackURL, event, callback); sendErr != nil {
+		logger.Errf("fail to queue callback to %s for %s: %v", callbackURL, callback.Pair, sendErr)
 	}
-	return quoteID, nil
+
+	return quoteID, err
 }
 
-func (q *Quotation) GetQuotationByID(quoteID uuid.UUID) (*models.Quote, error) {
-	quote, err := q.Repo.GetQuotation(quoteID)
+func (q *Quotation) GetQuotationByID(ctx context.Context, quoteID uuid.UUID) (*models.Quote, error) {
+	quote, err := q.Repo.GetQuotation(ctx, quoteID)
 	if err != nil {
 		return nil, errs.WithMessagef(err, "failed to GetQuotationByID, for: %v", quoteID)
 	}
@@ -69,18 +120,18 @@ func (q *Quotation) GetQuotationByID(quoteID uuid.UUID) (*models.Quote, error) {
 	return quote, nil
 }
 
-func (q *Quotation) GetLastUpdated(from, to string) (*models.Quote, error) {
-	quotePairs, err := q.Repo.GetQuotePairs()
+func (q *Quotation) GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error) {
+	quotePairs, err := q.Repo.GetQuotePairs(ctx)
 	if err != nil {
 		return nil, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v", from)
 	}
 
 	if len(quotePairs) == 0 {
-		quoteID, err := q.GetQuoteAsync(from, to)
+		quoteID, err := q.GetQuoteAsync(ctx, from, to)
 		if err != nil {
 			return nil, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v", from)
 		}
-		quote, err := q.GetQuotationByID(quoteID)
+		quote, err := q.GetQuotationByID(ctx, quoteID)
 		if err != nil {
 			return nil, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v", quoteID)
 		}
@@ -89,17 +140,17 @@ func (q *Quotation) GetLastUpdated(from, to string) (*models.Quote, error) {
 
 	for _, pair := range quotePairs {
 		if pair[0] == from && pair[1] == to {
-			quote, err := q.Repo.GetLastUpdated(from, to)
+			quote, err := q.Repo.GetLastUpdated(ctx, from, to)
 			if err != nil {
 				return nil, errs.WithMessagef(err, "failed to GetLastUpdated for %s/%s", from, to)
 			}
 			return quote, nil
 		} else {
-			quoteID, err := q.GetQuoteAsync(from, to)
+			quoteID, err := q.GetQuoteAsync(ctx, from, to)
 			if err != nil {
 				return nil, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v", from)
 			}
-			quote, err := q.GetQuotationByID(quoteID)
+			quote, err := q.GetQuotationByID(ctx, quoteID)
 			if err != nil {
 				return nil, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v", quoteID)
 			}
@@ -109,3 +160,59 @@ func (q *Quotation) GetLastUpdated(from, to string) (*models.Quote, error) {
 
 	return nil, errs.WithMessagef(err, "failed to GetLastUpdated, for: %v", from)
 }
+
+func (q *Quotation) GetStats(ctx context.Context, from, to string, window time.Duration) (*models.Stats, error) {
+	// keyed by the parsed window, so 7d and 168h share an entry
+	key := fmt.Sprintf("%s/%s:%s", from, to, window)
+	if q.StatsCache != nil {
+		if s, ok := q.StatsCache.Get(key); ok {
+			return s, nil
+		}
+	}
+
+	end := time.Now().UTC()
+	start := end.Add(-window)
+
+	quotes, err := q.Repo.GetQuotationsSince(ctx, from, to, start)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetQuotationsSince for %s/%s", from, to)
+	}
+
+	s, err := stats.Compute(quotes, start, end)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to compute stats for %s/%s", from, to)
+	}
+	s.Quote = fmt.Sprintf("%s/%s", from, to)
+
+	if q.StatsCache != nil {
+		q.StatsCache.Set(key, s)
+	}
+
+	return s, nil
+}
+
+// GetLatestForBase returns the freshest stored rate from base to every
+// tracked target currency.
+func (q *Quotation) GetLatestForBase(ctx context.Context, base string) (*models.BaseLatestResponse, error) {
+	quotes, err := q.Repo.GetLatestQuotes(ctx, base)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetLatestQuotes for %s", base)
+	}
+
+	resp := &models.BaseLatestResponse{Base: base, Rates: make(map[string]models.LatestResponse)}
+	for _, quote := range quotes {
+		resp.Rates[quote.TargetCurrency] = models.LatestResponse{Rate: quote.Rate, LastUpdated: quote.Timestamp}
+	}
+
+	return resp, nil
+}
+
+// GetMatrix returns the cross-rate table among the configured currencies.
+func (q *Quotation) GetMatrix(ctx context.Context) (*models.MatrixResponse, error) {
+	quotes, err := q.Repo.GetLatestQuotes(ctx, "")
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to GetLatestQuotes for matrix")
+	}
+
+	return BuildMatrix(q.Config.Quotations, quotes), nil
+}
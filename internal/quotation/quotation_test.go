@@ -2,13 +2,18 @@ package quotation
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/mashmorsik/logger"
 	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/internal/webhook"
 	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
 	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
 	"github.com/shopspring/decimal"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -21,8 +26,8 @@ func TestQuotation_GetLastUpdated(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().GetQuotePairs().Return([][]string{{"EUR", "USD"}}, nil)
-	mockRepo.EXPECT().GetLastUpdated("EUR", "USD").Return(&models.Quote{
+	mockRepo.EXPECT().GetQuotePairs(gomock.Any()).Return([][]string{{"EUR", "USD"}}, nil)
+	mockRepo.EXPECT().GetLastUpdated(gomock.Any(), "EUR", "USD").Return(&models.Quote{
 		ID:             uuid.MustParse("3f8a26f7-97f8-45a5-bda0-1af96b6b7d84"),
 		BaseCurrency:   "EUR",
 		TargetCurrency: "USD",
@@ -61,7 +66,7 @@ func TestQuotation_GetLastUpdated(t *testing.T) {
 					ResponseDelay: 2 * time.Second,
 				},
 			}
-			got, err := q.GetLastUpdated(tt.args.from, tt.args.to)
+			got, err := q.GetLastUpdated(context.Background(), tt.args.from, tt.args.to)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetLastUpdated() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -80,7 +85,7 @@ func TestQuotation_GetQuotationByID(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().GetQuotation(uuid.MustParse("3f8a26f7-97f8-45a5-bda0-1af96b6b7d84")).Return(&models.Quote{
+	mockRepo.EXPECT().GetQuotation(gomock.Any(), uuid.MustParse("3f8a26f7-97f8-45a5-bda0-1af96b6b7d84")).Return(&models.Quote{
 		ID:             uuid.MustParse("3f8a26f7-97f8-45a5-bda0-1af96b6b7d84"),
 		BaseCurrency:   "EUR",
 		TargetCurrency: "USD",
@@ -113,7 +118,7 @@ func TestQuotation_GetQuotationByID(t *testing.T) {
 				Repo:   mockRepo,
 				Config: &config.Config{},
 			}
-			got, err := q.GetQuotationByID(tt.ID)
+			got, err := q.GetQuotationByID(context.Background(), tt.ID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetQuotationByID() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -139,9 +144,16 @@ func TestQuotation_GetQuoteAsync(t *testing.T) {
 		Rate:           decimal.NewFromFloat(1.208),
 	}
 
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"base":"EUR","date":"2024-04-11","rates":{"USD":1.0731}}`))
+	}))
+	defer upstream.Close()
+
 	mockRepo := mock_repository.NewMockRepository(ctrl)
-	mockRepo.EXPECT().AddQuotePair("EUR", "USD").Return(nil)
-	mockRepo.EXPECT().GetLastUpdated("EUR", "USD").Return(quote, nil)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().LockQuotePair(gomock.Any(), "EUR", "USD").Return(nil)
+	mockRepo.EXPECT().AddQuotePair(gomock.Any(), "EUR", "USD").Return(true, nil)
+	mockRepo.EXPECT().GetLastUpdated(gomock.Any(), "EUR", "USD").Return(quote, nil)
 
 	type args struct {
 		from string
@@ -162,27 +174,107 @@ func TestQuotation_GetQuoteAsync(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{Quotations: []string{"EUR", "USD"}, ResponseDelay: 5 * time.Second}
+			conf.QuoteAPI.URL = upstream.URL + "/latest?from=%s&to=%s"
+
 			q := &Quotation{
-				Ctx:  context.Background(),
-				Repo: mockRepo,
-				Config: &config.Config{
-					Quotations: []string{"EUR", "USD"},
-					QuoteAPI: struct {
-						URL string `yaml:"url"`
-					}{
-						URL: "https://api.frankfurter.app/latest?from=EUR&to=USD",
-					},
-					ResponseDelay: 5 * time.Second,
-				},
+				Ctx:    context.Background(),
+				Repo:   mockRepo,
+				Config: conf,
 			}
-			got, err := q.GetQuoteAsync(tt.args.from, tt.args.to)
+			got, err := q.GetQuoteAsync(context.Background(), tt.args.from, tt.args.to)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetLastUpdated() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetQuoteAsync() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetLastUpdated() got = %v, want %v", got, tt.want)
+				t.Errorf("GetQuoteAsync() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestQuotation_UpdateQuote_callback(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("to") == "XXX" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"base":"EUR","date":"2024-04-11","rates":{"USD":1.0731}}`))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name      string
+		to        string
+		wantEvent string
+		wantErr   bool
+	}{
+		{name: "stored", to: "USD", wantEvent: webhook.EventQuoteUpdated},
+		{name: "failed", to: "XXX", wantEvent: webhook.EventQuoteFailed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mock_repository.NewMockRepository(ctrl)
+			if !tt.wantErr {
+				expectTx(mockRepo)
+				mockRepo.EXPECT().LockQuotePair(gomock.Any(), "EUR", tt.to).Return(nil)
+				mockRepo.EXPECT().AddQuotePair(gomock.Any(), "EUR", tt.to).Return(false, nil)
+				mockRepo.EXPECT().GetLastUpdated(gomock.Any(), "EUR", tt.to).Return(nil, nil)
+				mockRepo.EXPECT().AddQuotation(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().GetQuotation(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, id uuid.UUID) (*models.Quote, error) {
+					return &models.Quote{ID: id, BaseCurrency: "EUR", TargetCurrency: tt.to,
+						Rate: decimal.NewFromFloat(1.0731)}, nil
+				})
+			}
+
+			webhookRepo := mock_repository.NewMockWebhookRepository(ctrl)
+			webhookRepo.EXPECT().AddWebhookMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m *models.WebhookMessage) error {
+				if m.URL != "https://client.example/cb" || m.Event != tt.wantEvent || m.EndpointID != nil {
+					t.Errorf("unexpected message: %+v", m)
+				}
+
+				var envelope struct {
+					Data map[string]json.RawMessage `json:"data"`
+				}
+				if err := json.Unmarshal(m.Payload, &envelope); err != nil {
+					t.Fatalf("failed to unmarshal payload: %v", err)
+				}
+				_, hasID := envelope.Data["quoteID"]
+				_, hasQuote := envelope.Data["quote"]
+				_, hasErr := envelope.Data["error"]
+				if hasErr != tt.wantErr || hasID == tt.wantErr || hasQuote == tt.wantErr {
+					t.Errorf("unexpected callback: %s", m.Payload)
+				}
+				return nil
+			})
+
+			conf := &config.Config{ResponseDelay: 5 * time.Second}
+			conf.QuoteAPI.URL = upstream.URL + "/latest?from=%s&to=%s"
+
+			q := &Quotation{
+				Ctx:      context.Background(),
+				Repo:     mockRepo,
+				Config:   conf,
+				Webhooks: webhook.NewWebhook(context.Background(), webhookRepo, conf),
+			}
+			_, err := q.UpdateQuote(context.Background(), "EUR", tt.to, "https://client.example/cb")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpdateQuote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// expectTx runs WithTx callbacks against mockRepo itself.
+func expectTx(mockRepo *mock_repository.MockRepository) {
+	mockRepo.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, fn func(repository.Repository) error) error {
+			return fn(mockRepo)
+		})
+}
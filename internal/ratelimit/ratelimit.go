@@ -0,0 +1,230 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/internal/auth"
+	errs "github.com/pkg/errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultIdleTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result describes the state of a bucket after a request was counted.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter keeps one token bucket per route group and client. A client is an
+// API key when the request is authenticated, its IP address otherwise.
+type Limiter struct {
+	rules   map[string]config.RateLimitRule
+	trusted []*net.IPNet
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(conf *config.Config) (*Limiter, error) {
+	l := &Limiter{
+		rules:   make(map[string]config.RateLimitRule),
+		idleTTL: conf.RateLimit.IdleTTL,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	if l.idleTTL <= 0 {
+		l.idleTTL = defaultIdleTTL
+	}
+
+	for group, rule := range conf.RateLimit.Groups {
+		if rule.Rate <= 0 || rule.Burst <= 0 {
+			return nil, errs.Errorf("rate limit group %q: rate and burst must be positive", group)
+		}
+		l.rules[group] = rule
+	}
+
+	for _, p := range conf.RateLimit.TrustedProxies {
+		n, err := parseCIDR(p)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "invalid trusted proxy %q", p)
+		}
+		l.trusted = append(l.trusted, n)
+	}
+
+	return l, nil
+}
+
+// parseCIDR accepts a CIDR or a single address.
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, errs.New("not an IP address or CIDR")
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+// Allow takes a token from the client's bucket in group. Groups without a
+// rule are not limited.
+func (l *Limiter) Allow(group, client string) (Result, bool) {
+	rule, ok := l.rules[group]
+	if !ok {
+		return Result{Allowed: true}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	key := group + "|" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+now.Sub(b.last).Seconds()*rule.Rate)
+	b.last = now
+
+	res := Result{Limit: rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / rule.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = secondsToDuration((float64(rule.Burst) - b.tokens) / rule.Rate)
+
+	return res, true
+}
+
+// sweep drops buckets that were not used for idleTTL. Such buckets are full
+// again as long as idleTTL is longer than burst/rate, so forgetting them
+// does not change any decision.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ClientID identifies the caller of r: the authenticated API key, or else
+// the client IP.
+func (l *Limiter) ClientID(r *http.Request) string {
+	if k := auth.KeyFromContext(r.Context()); k != nil {
+		return "key:" + k.ID.String()
+	}
+	return "ip:" + l.ClientIP(r)
+}
+
+// ClientIP returns the peer address unless the peer is a trusted proxy, in
+// which case X-Forwarded-For is walked from the right and the first address
+// that is not a trusted proxy is returned.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !l.isTrusted(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !l.isTrusted(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+func (l *Limiter) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range l.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap limits h with the rule of group. Every limited response carries
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset; rejected ones
+// get 429 with Retry-After.
+func (l *Limiter) Wrap(group string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, limited := l.Allow(group, l.ClientID(r))
+		if !limited {
+			h(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			auth.WriteError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit exceeded for %s requests", group))
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
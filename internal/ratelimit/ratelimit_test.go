@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, now *time.Time) *Limiter {
+	t.Helper()
+
+	conf := &config.Config{}
+	conf.RateLimit.TrustedProxies = []string{"10.0.0.0/8", "127.0.0.1"}
+	conf.RateLimit.Groups = map[string]config.RateLimitRule{
+		"update": {Rate: 0.5, Burst: 2},
+	}
+
+	l, err := NewLimiter(conf)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(t, &now)
+
+	for i := 0; i < 2; i++ {
+		if res, _ := l.Allow("update", "a"); !res.Allowed {
+			t.Fatalf("request %d rejected within burst", i)
+		}
+	}
+
+	res, limited := l.Allow("update", "a")
+	if !limited || res.Allowed {
+		t.Fatalf("third request allowed, want rejected")
+	}
+	if res.RetryAfter != 2*time.Second {
+		t.Errorf("RetryAfter = %v, want 2s", res.RetryAfter)
+	}
+
+	if res, _ = l.Allow("update", "b"); !res.Allowed {
+		t.Errorf("other client rejected")
+	}
+	if _, limited = l.Allow("read", "a"); limited {
+		t.Errorf("group without rule was limited")
+	}
+
+	now = now.Add(2 * time.Second)
+	if res, _ = l.Allow("update", "a"); !res.Allowed {
+		t.Errorf("request after refill rejected")
+	}
+}
+
+func TestLimiter_ClientIP(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(t, &now)
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{name: "direct", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted peer ignores header", remote: "203.0.113.7:5000", xff: "198.51.100.1", want: "203.0.113.7"},
+		{name: "trusted proxy", remote: "127.0.0.1:5000", xff: "198.51.100.1", want: "198.51.100.1"},
+		{name: "proxy chain", remote: "10.0.0.2:5000", xff: "192.0.2.9, 198.51.100.1, 10.0.0.1", want: "198.51.100.1"},
+		{name: "garbage hop", remote: "127.0.0.1:5000", xff: "nope", want: "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/latest", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := l.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Wrap(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(t, &now)
+	h := l.Wrap("update", func(w http.ResponseWriter, r *http.Request) {})
+
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		rec = httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodPost, "/update", nil))
+	}
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"Retry-After":         "2",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "4",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestNewLimiter_invalid(t *testing.T) {
+	conf := &config.Config{}
+	conf.RateLimit.Groups = map[string]config.RateLimitRule{"read": {Rate: 1}}
+	if _, err := NewLimiter(conf); err == nil {
+		t.Error("expected error for zero burst")
+	}
+
+	conf = &config.Config{}
+	conf.RateLimit.TrustedProxies = []string{"proxy.local"}
+	if _, err := NewLimiter(conf); err == nil {
+		t.Error("expected error for invalid trusted proxy")
+	}
+}
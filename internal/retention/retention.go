@@ -0,0 +1,269 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/repository"
+	errs "github.com/pkg/errors"
+	"time"
+)
+
+const (
+	defaultInterval        = time.Hour
+	defaultBatchSize       = 1000
+	defaultPartitionsAhead = 3
+	day                    = 24 * time.Hour
+	// hourlyWindow and dailyWindow bound the source rows a single rollup
+	// statement aggregates.
+	hourlyWindow = day
+	dailyWindow  = 30 * day
+)
+
+// Retention rolls raw quotations up into hourly and daily OHLC buckets and
+// deletes every tier once it is older than its configured retention.
+type Retention struct {
+	Ctx    context.Context
+	Repo   repository.RetentionRepository
+	Config *config.Config
+	// Partitions, when set, keeps monthly quotation partitions created
+	// ahead and removes expired ones whole before deleting raw rows.
+	Partitions repository.PartitionRepository
+	// Webhooks, when set, has finished outbox messages and the delivery log
+	// pruned after the webhooks retention.
+	Webhooks repository.WebhookRepository
+	now      func() time.Time
+}
+
+func NewRetention(ctx context.Context, repo repository.RetentionRepository, conf *config.Config) *Retention {
+	return &Retention{Ctx: ctx, Repo: repo, Config: conf, now: time.Now}
+}
+
+// Run compacts once per interval until the context is done.
+func (r *Retention) Run() {
+	interval := r.Config.Retention.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Compact(r.Ctx); err != nil {
+				logger.Errf("fail to compact quotation history: %v", err)
+			}
+		}
+	}
+}
+
+// Compact rolls up every complete hour and day and then deletes expired
+// rows in batches. Rows are only deleted once they are covered by the next
+// tier, so a failed rollup never loses data; it only holds back deletes of
+// the rows it did not cover. Every step runs even if an earlier one failed,
+// and their errors are returned together.
+func (r *Retention) Compact(ctx context.Context) error {
+	now := r.now().UTC()
+	hourEnd := now.Truncate(time.Hour)
+	dayEnd := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var errList []error
+	if r.Partitions != nil {
+		if err := r.createPartitions(ctx, now); err != nil {
+			errList = append(errList, err)
+		}
+	}
+
+	hourlyDone, err := r.rollup(ctx, "hourly", r.Repo.HourlyWatermark, r.Repo.RollupHourly,
+		time.Hour, hourlyWindow, hourEnd)
+	if err != nil {
+		errList = append(errList, err)
+	}
+
+	// days are only rolled up from complete hourly buckets
+	dailyEnd := earliest(dayEnd, hourlyDone.Truncate(day))
+	dailyDone, err := r.rollup(ctx, "daily", r.Repo.DailyWatermark, r.Repo.RollupDaily,
+		day, dailyWindow, dailyEnd)
+	if err != nil {
+		errList = append(errList, err)
+	}
+
+	policy := r.Config.Retention
+
+	if policy.Raw > 0 && !hourlyDone.IsZero() {
+		cutoff := earliest(now.Add(-policy.Raw), hourlyDone)
+		if r.Partitions != nil {
+			if err = r.removePartitions(ctx, cutoff); err != nil {
+				errList = append(errList, err)
+			}
+		}
+		if err = r.deleteInBatches(ctx, "raw", r.Repo.DeleteRawBefore, cutoff); err != nil {
+			errList = append(errList, err)
+		}
+	}
+
+	if policy.Hourly > 0 && !dailyDone.IsZero() {
+		cutoff := earliest(now.Add(-policy.Hourly), dailyDone)
+		if err = r.deleteInBatches(ctx, "hourly", r.Repo.DeleteHourlyBefore, cutoff); err != nil {
+			errList = append(errList, err)
+		}
+	}
+
+	if policy.Daily > 0 {
+		if err = r.deleteInBatches(ctx, "daily", r.Repo.DeleteDailyBefore, now.Add(-policy.Daily)); err != nil {
+			errList = append(errList, err)
+		}
+	}
+
+	if r.Webhooks != nil && policy.Webhooks > 0 {
+		cutoff := now.Add(-policy.Webhooks)
+		if err = r.deleteInBatches(ctx, "webhook message", r.Webhooks.DeleteWebhookMessagesBefore, cutoff); err != nil {
+			errList = append(errList, err)
+		}
+		if err = r.deleteInBatches(ctx, "webhook delivery", r.Webhooks.DeleteWebhookDeliveriesBefore, cutoff); err != nil {
+			errList = append(errList, err)
+		}
+	}
+
+	return errors.Join(errList...)
+}
+
+// rollup rolls a tier up from its watermark to end, one window per
+// statement so that no statement covers the whole history, and returns the
+// time up to which the tier is complete: end, or where the window that
+// failed starts. It is zero when the watermark cannot be read.
+func (r *Retention) rollup(ctx context.Context, tier string,
+	watermark func(context.Context) (time.Time, error),
+	roll func(context.Context, time.Time, time.Time) (int64, error),
+	bucket, window time.Duration, end time.Time) (time.Time, error) {
+	from, err := watermark(ctx)
+	if err != nil {
+		return time.Time{}, errs.WithMessagef(err, "failed to get %s watermark", tier)
+	}
+	if from.IsZero() {
+		// nothing to roll up yet
+		return end, nil
+	}
+	// recompute the last bucket in case it was rolled up early
+	from = from.Add(-bucket)
+
+	var total int64
+	for from.Before(end) {
+		if err = ctx.Err(); err != nil {
+			return from, err
+		}
+
+		to := earliest(from.Add(window), end)
+		n, err := roll(ctx, from, to)
+		if err != nil {
+			return from, errs.WithMessagef(err, "failed to roll up %s buckets from %s to %s", tier, from, to)
+		}
+		total += n
+		from = to
+	}
+	logger.Infof("rolled up %d %s buckets before %s", total, tier, end)
+
+	return end, nil
+}
+
+// createPartitions makes sure the current month and the configured number of
+// months ahead have a quotation partition.
+func (r *Retention) createPartitions(ctx context.Context, now time.Time) error {
+	ahead := r.Config.Retention.PartitionsAhead
+	if ahead <= 0 {
+		ahead = defaultPartitionsAhead
+	}
+
+	existing, err := r.Partitions.QuotationPartitions(ctx)
+	if err != nil {
+		return errs.WithMessage(err, "failed to list quotation partitions")
+	}
+	has := make(map[time.Time]bool, len(existing))
+	for _, month := range existing {
+		has[month] = true
+	}
+
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= ahead; i++ {
+		month := current.AddDate(0, i, 0)
+		if has[month] {
+			continue
+		}
+		if err = r.Partitions.CreateQuotationPartition(ctx, month); err != nil {
+			return errs.WithMessagef(err, "failed to create quotation partition for %s", month.Format("2006-01"))
+		}
+		logger.Infof("created quotation partition for %s", month.Format("2006-01"))
+	}
+
+	return nil
+}
+
+// removePartitions detaches or drops every quotation partition that ends at
+// or before cutoff.
+func (r *Retention) removePartitions(ctx context.Context, cutoff time.Time) error {
+	months, err := r.Partitions.QuotationPartitions(ctx)
+	if err != nil {
+		return errs.WithMessage(err, "failed to list quotation partitions")
+	}
+
+	detach := r.Config.Retention.DetachPartitions
+	for _, month := range months {
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+
+		removed, err := r.Partitions.RemoveQuotationPartition(ctx, month, detach)
+		if err != nil {
+			return errs.WithMessagef(err, "failed to remove quotation partition for %s", month.Format("2006-01"))
+		}
+		if removed {
+			logger.Infof("removed quotation partition for %s, detached: %v", month.Format("2006-01"), detach)
+		}
+	}
+
+	return nil
+}
+
+// deleteInBatches keeps deleting until a batch comes back short, so every
+// statement only holds its locks for one batch.
+func (r *Retention) deleteInBatches(ctx context.Context, tier string, del func(context.Context, time.Time, int) (int64, error), cutoff time.Time) error {
+	batch := r.Config.Retention.BatchSize
+	if batch <= 0 {
+		batch = defaultBatchSize
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := del(ctx, cutoff, batch)
+		if err != nil {
+			return errs.WithMessagef(err, "failed to delete %s rows before %s", tier, cutoff)
+		}
+		total += n
+
+		if n < int64(batch) {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Infof("deleted %d %s rows before %s", total, tier, cutoff)
+	}
+
+	return nil
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,220 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	"testing"
+	"time"
+)
+
+func TestRetention_Compact(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 4, 11, 15, 42, 0, 0, time.UTC)
+	hourEnd := time.Date(2024, 4, 11, 15, 0, 0, 0, time.UTC)
+
+	conf := &config.Config{}
+	conf.Retention.Raw = 30 * day
+	conf.Retention.Hourly = 2 * 365 * day
+	conf.Retention.BatchSize = 2
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockRetentionRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().HourlyWatermark(gomock.Any()).Return(hourEnd, nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-time.Hour), hourEnd).Return(int64(3), nil),
+		// no hourly buckets yet, so there is nothing to roll up into days
+		repo.EXPECT().DailyWatermark(gomock.Any()).Return(time.Time{}, nil),
+		repo.EXPECT().DeleteRawBefore(gomock.Any(), now.Add(-30*day), 2).Return(int64(2), nil),
+		repo.EXPECT().DeleteRawBefore(gomock.Any(), now.Add(-30*day), 2).Return(int64(2), nil),
+		repo.EXPECT().DeleteRawBefore(gomock.Any(), now.Add(-30*day), 2).Return(int64(1), nil),
+		repo.EXPECT().DeleteHourlyBefore(gomock.Any(), now.Add(-2*365*day), 2).Return(int64(0), nil),
+	)
+
+	r := NewRetention(context.Background(), repo, conf)
+	r.now = func() time.Time { return now }
+
+	if err := r.Compact(context.Background()); err != nil {
+		t.Errorf("Compact() error = %v", err)
+	}
+}
+
+func TestRetention_Compact_cutoff_never_passes_rollup(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 4, 11, 15, 42, 0, 0, time.UTC)
+	hourEnd := time.Date(2024, 4, 11, 15, 0, 0, 0, time.UTC)
+	dayEnd := time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)
+
+	conf := &config.Config{}
+	conf.Retention.Raw = time.Minute
+	conf.Retention.Hourly = time.Hour
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockRetentionRepository(ctrl)
+	repo.EXPECT().HourlyWatermark(gomock.Any()).Return(hourEnd, nil)
+	repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-time.Hour), hourEnd).Return(int64(0), nil)
+	repo.EXPECT().DailyWatermark(gomock.Any()).Return(dayEnd, nil)
+	repo.EXPECT().RollupDaily(gomock.Any(), dayEnd.Add(-day), dayEnd).Return(int64(0), nil)
+	repo.EXPECT().DeleteRawBefore(gomock.Any(), hourEnd, defaultBatchSize).Return(int64(0), nil)
+	repo.EXPECT().DeleteHourlyBefore(gomock.Any(), dayEnd, defaultBatchSize).Return(int64(0), nil)
+
+	r := NewRetention(context.Background(), repo, conf)
+	r.now = func() time.Time { return now }
+
+	if err := r.Compact(context.Background()); err != nil {
+		t.Errorf("Compact() error = %v", err)
+	}
+}
+
+func TestRetention_Compact_partitions(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 4, 11, 15, 42, 0, 0, time.UTC)
+	hourEnd := time.Date(2024, 4, 11, 15, 0, 0, 0, time.UTC)
+	dayEnd := time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC) }
+
+	conf := &config.Config{}
+	conf.Retention.Raw = 30 * day
+	conf.Retention.PartitionsAhead = 2
+	conf.Retention.DetachPartitions = true
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	existing := []time.Time{month(1), month(2), month(3), month(4), month(5)}
+
+	repo := mock_repository.NewMockRetentionRepository(ctrl)
+	partitions := mock_repository.NewMockPartitionRepository(ctrl)
+	gomock.InOrder(
+		partitions.EXPECT().QuotationPartitions(gomock.Any()).Return(existing, nil),
+		partitions.EXPECT().CreateQuotationPartition(gomock.Any(), month(6)).Return(nil),
+		repo.EXPECT().HourlyWatermark(gomock.Any()).Return(hourEnd, nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-time.Hour), hourEnd).Return(int64(0), nil),
+		repo.EXPECT().DailyWatermark(gomock.Any()).Return(dayEnd, nil),
+		repo.EXPECT().RollupDaily(gomock.Any(), dayEnd.Add(-day), dayEnd).Return(int64(0), nil),
+		partitions.EXPECT().QuotationPartitions(gomock.Any()).Return(existing, nil),
+		// the cutoff is March 12th, so only January and February are whole
+		partitions.EXPECT().RemoveQuotationPartition(gomock.Any(), month(1), true).Return(false, nil),
+		partitions.EXPECT().RemoveQuotationPartition(gomock.Any(), month(2), true).Return(true, nil),
+		repo.EXPECT().DeleteRawBefore(gomock.Any(), now.Add(-30*day), defaultBatchSize).Return(int64(0), nil),
+	)
+
+	r := NewRetention(context.Background(), repo, conf)
+	r.Partitions = partitions
+	r.now = func() time.Time { return now }
+
+	if err := r.Compact(context.Background()); err != nil {
+		t.Errorf("Compact() error = %v", err)
+	}
+}
+
+func TestRetention_Compact_webhooks(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 4, 11, 15, 42, 0, 0, time.UTC)
+	hourEnd := time.Date(2024, 4, 11, 15, 0, 0, 0, time.UTC)
+	dayEnd := time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)
+
+	conf := &config.Config{}
+	conf.Retention.Webhooks = 30 * day
+	conf.Retention.BatchSize = 2
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockRetentionRepository(ctrl)
+	webhooks := mock_repository.NewMockWebhookRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().HourlyWatermark(gomock.Any()).Return(hourEnd, nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-time.Hour), hourEnd).Return(int64(0), nil),
+		repo.EXPECT().DailyWatermark(gomock.Any()).Return(dayEnd, nil),
+		repo.EXPECT().RollupDaily(gomock.Any(), dayEnd.Add(-day), dayEnd).Return(int64(0), nil),
+		webhooks.EXPECT().DeleteWebhookMessagesBefore(gomock.Any(), now.Add(-30*day), 2).Return(int64(2), nil),
+		webhooks.EXPECT().DeleteWebhookMessagesBefore(gomock.Any(), now.Add(-30*day), 2).Return(int64(0), nil),
+		webhooks.EXPECT().DeleteWebhookDeliveriesBefore(gomock.Any(), now.Add(-30*day), 2).Return(int64(1), nil),
+	)
+
+	r := NewRetention(context.Background(), repo, conf)
+	r.Webhooks = webhooks
+	r.now = func() time.Time { return now }
+
+	if err := r.Compact(context.Background()); err != nil {
+		t.Errorf("Compact() error = %v", err)
+	}
+}
+
+func TestRetention_Compact_windows(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 4, 11, 15, 42, 0, 0, time.UTC)
+	hourEnd := time.Date(2024, 4, 11, 15, 0, 0, 0, time.UTC)
+	dayEnd := time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)
+	// seeded from the oldest raw quotation, nothing is rolled up yet
+	oldest := hourEnd.Add(-50 * time.Hour)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockRetentionRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().HourlyWatermark(gomock.Any()).Return(oldest, nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-51*time.Hour), hourEnd.Add(-27*time.Hour)).Return(int64(24), nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-27*time.Hour), hourEnd.Add(-3*time.Hour)).Return(int64(24), nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-3*time.Hour), hourEnd).Return(int64(3), nil),
+		repo.EXPECT().DailyWatermark(gomock.Any()).Return(oldest.Truncate(day), nil),
+		repo.EXPECT().RollupDaily(gomock.Any(), oldest.Truncate(day).Add(-day), dayEnd).Return(int64(3), nil),
+	)
+
+	r := NewRetention(context.Background(), repo, &config.Config{})
+	r.now = func() time.Time { return now }
+
+	if err := r.Compact(context.Background()); err != nil {
+		t.Errorf("Compact() error = %v", err)
+	}
+}
+
+func TestRetention_Compact_rollup_failure(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Date(2024, 4, 11, 15, 42, 0, 0, time.UTC)
+	hourEnd := time.Date(2024, 4, 11, 15, 0, 0, 0, time.UTC)
+	failedAt := hourEnd.Add(-27 * time.Hour)
+
+	conf := &config.Config{}
+	conf.Retention.Raw = time.Hour
+	conf.Retention.Hourly = time.Hour
+	conf.Retention.Daily = 365 * day
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockRetentionRepository(ctrl)
+	gomock.InOrder(
+		repo.EXPECT().HourlyWatermark(gomock.Any()).Return(hourEnd.Add(-50*time.Hour), nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), hourEnd.Add(-51*time.Hour), failedAt).Return(int64(24), nil),
+		repo.EXPECT().RollupHourly(gomock.Any(), failedAt, hourEnd.Add(-3*time.Hour)).Return(int64(0), errors.New("timeout")),
+		// days are only rolled up as far as the hourly buckets are complete
+		repo.EXPECT().DailyWatermark(gomock.Any()).Return(time.Time{}, nil),
+		// raw rows are still deleted as far as they are rolled up
+		repo.EXPECT().DeleteRawBefore(gomock.Any(), failedAt, defaultBatchSize).Return(int64(0), nil),
+		repo.EXPECT().DeleteHourlyBefore(gomock.Any(), failedAt.Truncate(day), defaultBatchSize).Return(int64(0), nil),
+		repo.EXPECT().DeleteDailyBefore(gomock.Any(), now.Add(-365*day), defaultBatchSize).Return(int64(0), nil),
+	)
+
+	r := NewRetention(context.Background(), repo, conf)
+	r.now = func() time.Time { return now }
+
+	if err := r.Compact(context.Background()); err == nil {
+		t.Errorf("Compact() error = nil, want the rollup error")
+	}
+}
@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"github.com/mashmorsik/quotation/pkg/models"
+	"sync"
+	"time"
+)
+
+type cacheItem struct {
+	stats   *models.Stats
+	expires time.Time
+}
+
+// maxCacheEntries bounds the cache; windows are client supplied, so the
+// number of distinct keys is not.
+const maxCacheEntries = 1024
+
+// Cache keeps computed statistics per pair and window for a fixed TTL, at
+// most maxCacheEntries of them.
+type Cache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	items map[string]cacheItem
+	now   func() time.Time
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, items: make(map[string]cacheItem), now: time.Now}
+}
+
+func (c *Cache) Get(key string) (*models.Stats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(item.expires) {
+		delete(c.items, key)
+		return nil, false
+	}
+
+	return item.stats, true
+}
+
+func (c *Cache) Set(key string, s *models.Stats) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.items[key]; !ok && len(c.items) >= maxCacheEntries {
+		c.evict(now)
+	}
+
+	c.items[key] = cacheItem{stats: s, expires: now.Add(c.ttl)}
+}
+
+// evict drops the expired items or, when none expired, the one expiring
+// first.
+func (c *Cache) evict(now time.Time) {
+	var (
+		oldest  string
+		expires time.Time
+	)
+	for key, item := range c.items {
+		if now.After(item.expires) {
+			delete(c.items, key)
+			continue
+		}
+		if expires.IsZero() || item.expires.Before(expires) {
+			oldest, expires = key, item.expires
+		}
+	}
+
+	if len(c.items) >= maxCacheEntries {
+		delete(c.items, oldest)
+	}
+}
@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"errors"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/shopspring/decimal"
+	"math"
+	"time"
+)
+
+var ErrNoData = errors.New("no quotations in window")
+
+// Compute calculates rolling statistics over quotes, which must be sorted by
+// Timestamp in ascending order and fall within [start, end].
+func Compute(quotes []*models.Quote, start, end time.Time) (*models.Stats, error) {
+	if len(quotes) == 0 {
+		return nil, ErrNoData
+	}
+
+	first, last := quotes[0], quotes[len(quotes)-1]
+
+	s := &models.Stats{
+		From:   start,
+		To:     end,
+		Points: len(quotes),
+		Min:    first.Rate,
+		Max:    first.Rate,
+	}
+
+	sum := decimal.Zero
+	for _, q := range quotes {
+		if q.Rate.LessThan(s.Min) {
+			s.Min = q.Rate
+		}
+		if q.Rate.GreaterThan(s.Max) {
+			s.Max = q.Rate
+		}
+		sum = sum.Add(q.Rate)
+	}
+	s.Mean = sum.Div(decimal.NewFromInt(int64(len(quotes))))
+	s.TWAP = twap(quotes, end, s.Mean)
+	s.Volatility = volatility(quotes)
+
+	if !first.Rate.IsZero() {
+		s.ChangePercent = last.Rate.Sub(first.Rate).Div(first.Rate).Mul(decimal.NewFromInt(100))
+	}
+
+	return s, nil
+}
+
+// twap weights every rate by the time it stayed current: until the next
+// quote or, for the last one, until end.
+func twap(quotes []*models.Quote, end time.Time, fallback decimal.Decimal) decimal.Decimal {
+	weighted := decimal.Zero
+	total := decimal.Zero
+
+	for i, q := range quotes {
+		next := end
+		if i+1 < len(quotes) {
+			next = quotes[i+1].Timestamp
+		}
+		if !next.After(q.Timestamp) {
+			continue
+		}
+
+		dt := decimal.NewFromFloat(next.Sub(q.Timestamp).Seconds())
+		weighted = weighted.Add(q.Rate.Mul(dt))
+		total = total.Add(dt)
+	}
+
+	if total.IsZero() {
+		return fallback
+	}
+	return weighted.Div(total)
+}
+
+// volatility is the sample standard deviation of log returns.
+func volatility(quotes []*models.Quote) float64 {
+	returns := make([]float64, 0, len(quotes))
+	for i := 1; i < len(quotes); i++ {
+		prev, _ := quotes[i-1].Rate.Float64()
+		cur, _ := quotes[i].Rate.Float64()
+		if prev <= 0 || cur <= 0 {
+			continue
+		}
+		returns = append(returns, math.Log(cur/prev))
+	}
+
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	return math.Sqrt(variance)
+}
@@ -0,0 +1,173 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+func series(rates ...float64) []*models.Quote {
+	quotes := make([]*models.Quote, 0, len(rates))
+	for i, r := range rates {
+		quotes = append(quotes, &models.Quote{
+			ID:             uuid.New(),
+			BaseCurrency:   "EUR",
+			TargetCurrency: "USD",
+			Timestamp:      start.Add(time.Duration(i) * time.Hour),
+			Rate:           decimal.NewFromFloat(r),
+		})
+	}
+	return quotes
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name          string
+		quotes        []*models.Quote
+		end           time.Time
+		wantMin       string
+		wantMax       string
+		wantMean      string
+		wantTWAP      string
+		wantChange    string
+		wantVol       float64
+		wantPointsNum int
+	}{
+		{
+			name:          "single_point",
+			quotes:        series(1.1),
+			end:           start.Add(time.Hour),
+			wantMin:       "1.1",
+			wantMax:       "1.1",
+			wantMean:      "1.1",
+			wantTWAP:      "1.1",
+			wantChange:    "0",
+			wantVol:       0,
+			wantPointsNum: 1,
+		},
+		{
+			name:          "rising_series",
+			quotes:        series(1, 2, 4),
+			end:           start.Add(3 * time.Hour),
+			wantMin:       "1",
+			wantMax:       "4",
+			wantMean:      "2.3333333333333333",
+			wantTWAP:      "2.3333333333333333",
+			wantChange:    "300",
+			wantVol:       0,
+			wantPointsNum: 3,
+		},
+		{
+			name:          "last_point_held_until_end",
+			quotes:        series(1, 3),
+			end:           start.Add(4 * time.Hour),
+			wantMin:       "1",
+			wantMax:       "3",
+			wantMean:      "2",
+			wantTWAP:      "2.5",
+			wantChange:    "200",
+			wantVol:       0,
+			wantPointsNum: 2,
+		},
+		{
+			name:          "oscillating_series",
+			quotes:        series(1, 2, 1),
+			end:           start.Add(2 * time.Hour),
+			wantMin:       "1",
+			wantMax:       "2",
+			wantMean:      "1.3333333333333333",
+			wantTWAP:      "1.5",
+			wantChange:    "0",
+			wantVol:       math.Sqrt2 * math.Ln2,
+			wantPointsNum: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compute(tt.quotes, start, tt.end)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if got.Points != tt.wantPointsNum {
+				t.Errorf("Points = %v, want %v", got.Points, tt.wantPointsNum)
+			}
+			for _, c := range []struct {
+				field string
+				got   decimal.Decimal
+				want  string
+			}{
+				{"Min", got.Min, tt.wantMin},
+				{"Max", got.Max, tt.wantMax},
+				{"Mean", got.Mean, tt.wantMean},
+				{"TWAP", got.TWAP, tt.wantTWAP},
+				{"ChangePercent", got.ChangePercent, tt.wantChange},
+			} {
+				if !c.got.Equal(decimal.RequireFromString(c.want)) {
+					t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+				}
+			}
+			if math.Abs(got.Volatility-tt.wantVol) > 1e-9 {
+				t.Errorf("Volatility = %v, want %v", got.Volatility, tt.wantVol)
+			}
+		})
+	}
+}
+
+func TestCompute_no_data(t *testing.T) {
+	_, err := Compute(nil, start, start.Add(time.Hour))
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("Compute() error = %v, want %v", err, ErrNoData)
+	}
+}
+
+func TestCache(t *testing.T) {
+	now := start
+	c := NewCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	want := &models.Stats{Quote: "EUR/USD"}
+	c.Set("EUR/USD:24h0m0s", want)
+
+	if got, ok := c.Get("EUR/USD:24h0m0s"); !ok || got != want {
+		t.Errorf("Get() = %v, %v, want %v, true", got, ok, want)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("EUR/USD:24h0m0s"); ok {
+		t.Errorf("Get() returned expired item")
+	}
+}
+
+func TestCache_bounded(t *testing.T) {
+	now := start
+	c := NewCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("EUR/USD:expired", &models.Stats{})
+	now = now.Add(2 * time.Minute)
+
+	for i := 0; i < 2*maxCacheEntries; i++ {
+		c.Set(fmt.Sprintf("EUR/USD:%ds", i), &models.Stats{})
+		now = now.Add(time.Millisecond)
+	}
+
+	if len(c.items) != maxCacheEntries {
+		t.Fatalf("cache holds %d items, want %d", len(c.items), maxCacheEntries)
+	}
+	if _, ok := c.items["EUR/USD:expired"]; ok {
+		t.Errorf("expired item was kept")
+	}
+	if _, ok := c.Get(fmt.Sprintf("EUR/USD:%ds", 2*maxCacheEntries-1)); !ok {
+		t.Errorf("Get() missed the newest item")
+	}
+	if _, ok := c.Get("EUR/USD:0s"); ok {
+		t.Errorf("Get() returned the oldest item, want it evicted")
+	}
+}
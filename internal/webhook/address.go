@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	errs "github.com/pkg/errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// checkAddr rejects the addresses a webhook must not reach: loopback,
+// private, link-local, where cloud metadata services such as
+// 169.254.169.254 live, multicast and unspecified ones.
+func checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return errs.WithMessagef(ErrForbiddenAddress, "%s", addr)
+	}
+	return nil
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL whose host only
+// resolves to addresses webhooks may reach. Delivery checks the address
+// again when dialing, as DNS may answer differently by then.
+func (wh *Webhook) ValidateURL(ctx context.Context, raw string) error {
+	if raw == "" {
+		return errors.New("url is required")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+
+	if wh.Config.Webhook.AllowPrivateNetworks {
+		return nil
+	}
+
+	addrs, err := wh.lookup(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errs.Errorf("url host %s cannot be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if err = checkAddr(addr); err != nil {
+			return errs.WithMessagef(err, "url host %s resolves to a forbidden address", u.Hostname())
+		}
+	}
+
+	return nil
+}
+
+// newClient returns the delivery client. It refuses to dial forbidden
+// addresses and does not follow redirects, which could lead there too.
+func (wh *Webhook) newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !wh.Config.Webhook.AllowPrivateNetworks {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return errs.WithMessagef(ErrForbiddenAddress, "%s", address)
+			}
+			return checkAddr(addrPort.Addr())
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the target, defeating the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func lookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
@@ -0,0 +1,368 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	errs "github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+const (
+	EventQuoteUpdated = "quote.updated"
+	EventQuoteFailed  = "quote.failed"
+	EventAlertFired   = "alert.fired"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultBackoffBase  = 10 * time.Second
+	defaultBackoffMax   = time.Hour
+	defaultBatchSize    = 50
+	deliveriesLimit     = 100
+)
+
+type Webhook struct {
+	Ctx    context.Context
+	Repo   repository.WebhookRepository
+	Config *config.Config
+	Client *http.Client
+	now    func() time.Time
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+func NewWebhook(ctx context.Context, repo repository.WebhookRepository, conf *config.Config) *Webhook {
+	wh := &Webhook{Ctx: ctx, Repo: repo, Config: conf, now: time.Now, lookup: lookupNetIP}
+	wh.Client = wh.newClient(wh.timeout())
+	return wh
+}
+
+// Backoff returns the delay before the next delivery attempt: base doubled
+// for every failed attempt and capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return min(d, max)
+}
+
+func (wh *Webhook) CreateEndpoint(ctx context.Context, e *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	e.ID = uuid.New()
+	e.CreatedAt = wh.now().UTC()
+	if e.Events == nil {
+		e.Events = []string{}
+	}
+
+	if e.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		e.Secret = secret
+	}
+
+	if err := wh.Repo.AddWebhookEndpoint(ctx, e); err != nil {
+		return nil, errs.WithMessagef(err, "failed to AddWebhookEndpoint for %s", e.URL)
+	}
+
+	return e, nil
+}
+
+func (wh *Webhook) GetEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	endpoints, err := wh.Repo.GetWebhookEndpoints(ctx)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to GetWebhookEndpoints")
+	}
+	for _, e := range endpoints {
+		e.Secret = ""
+	}
+	return endpoints, nil
+}
+
+func (wh *Webhook) GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	e, err := wh.Repo.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetWebhookEndpoint, for: %v", id)
+	}
+	if e != nil {
+		e.Secret = ""
+	}
+	return e, nil
+}
+
+func (wh *Webhook) SetEndpointEnabled(ctx context.Context, id uuid.UUID, enabled bool) error {
+	if err := wh.Repo.SetWebhookEndpointEnabled(ctx, id, enabled); err != nil {
+		return errs.WithMessagef(err, "failed to SetWebhookEndpointEnabled, for: %v", id)
+	}
+	return nil
+}
+
+func (wh *Webhook) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	if err := wh.Repo.DeleteWebhookEndpoint(ctx, id); err != nil {
+		return errs.WithMessagef(err, "failed to DeleteWebhookEndpoint, for: %v", id)
+	}
+	return nil
+}
+
+func (wh *Webhook) GetDeliveries(ctx context.Context, endpointID uuid.UUID) ([]*models.WebhookDelivery, error) {
+	deliveries, err := wh.Repo.GetWebhookDeliveries(ctx, endpointID, deliveriesLimit)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to GetWebhookDeliveries, for: %v", endpointID)
+	}
+	return deliveries, nil
+}
+
+// Publish puts event into the outbox of every enabled endpoint subscribed
+// to it.
+func (wh *Webhook) Publish(ctx context.Context, event string, data any) error {
+	endpoints, err := wh.Repo.GetWebhookEndpointsForEvent(ctx, event)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to GetWebhookEndpointsForEvent for %s", event)
+	}
+
+	for _, e := range endpoints {
+		endpointID := e.ID
+		if err = wh.enqueue(ctx, &endpointID, e.URL, e.Secret, event, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Send puts event into the outbox for a single URL that is not a registered
+// endpoint. Such messages are signed with the service-wide secret.
+func (wh *Webhook) Send(ctx context.Context, url, event string, data any) error {
+	return wh.enqueue(ctx, nil, url, "", event, data)
+}
+
+func (wh *Webhook) enqueue(ctx context.Context, endpointID *uuid.UUID, url, secret, event string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to marshal %s payload", event)
+	}
+
+	now := wh.now().UTC()
+	envelope := models.WebhookEnvelope{ID: uuid.New(), Event: event, CreatedAt: now, Data: raw}
+
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to marshal %s envelope", event)
+	}
+
+	m := &models.WebhookMessage{
+		ID:            envelope.ID,
+		EndpointID:    endpointID,
+		URL:           url,
+		Secret:        secret,
+		Event:         event,
+		Payload:       payload,
+		Status:        models.WebhookStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err = wh.Repo.AddWebhookMessage(ctx, m); err != nil {
+		return errs.WithMessagef(err, "failed to AddWebhookMessage for %s", url)
+	}
+
+	return nil
+}
+
+// Run delivers due messages every poll interval until the context is done.
+func (wh *Webhook) Run() {
+	ticker := time.NewTicker(wh.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wh.Ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := wh.DeliverPending(wh.Ctx); err != nil {
+				logger.Errf("fail to deliver webhooks: %v", err)
+			}
+		}
+	}
+}
+
+// DeliverPending attempts one batch of due outbox messages and returns the
+// number of successful deliveries. The batch is leased long enough to post
+// every message in turn; messages that could not be started well within the
+// lease are left to be claimed again once it expires, so no other worker
+// delivers them meanwhile.
+func (wh *Webhook) DeliverPending(ctx context.Context) (int, error) {
+	now := wh.now().UTC()
+	leaseUntil := now.Add(time.Duration(wh.batchSize()) * wh.timeout())
+
+	messages, err := wh.Repo.ClaimDueWebhookMessages(ctx, now, leaseUntil, wh.batchSize())
+	if err != nil {
+		return 0, errs.WithMessage(err, "failed to ClaimDueWebhookMessages")
+	}
+
+	delivered := 0
+	for i, m := range messages {
+		if wh.now().Add(wh.timeout()).After(leaseUntil) {
+			logger.Warn(fmt.Sprintf("webhook lease is running out, %d messages are left for the next claim", len(messages)-i))
+			break
+		}
+
+		ok, err := wh.deliver(ctx, m)
+		if err != nil {
+			logger.Errf("fail to record webhook delivery %s: %v", m.ID, err)
+			continue
+		}
+		if ok {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+func (wh *Webhook) deliver(ctx context.Context, m *models.WebhookMessage) (bool, error) {
+	m.Attempts++
+	started := wh.now()
+
+	statusCode, sendErr := wh.post(ctx, m)
+
+	delivery := &models.WebhookDelivery{
+		ID:          uuid.New(),
+		MessageID:   m.ID,
+		EndpointID:  m.EndpointID,
+		URL:         m.URL,
+		Event:       m.Event,
+		Attempt:     m.Attempts,
+		StatusCode:  statusCode,
+		Duration:    models.Duration(wh.now().Sub(started)),
+		DeliveredAt: started.UTC(),
+	}
+
+	ok := sendErr == nil
+	if ok {
+		m.Status = models.WebhookStatusDelivered
+		m.LastError = ""
+	} else {
+		delivery.Error = sendErr.Error()
+		m.LastError = sendErr.Error()
+		if m.Attempts >= wh.maxAttempts() {
+			m.Status = models.WebhookStatusFailed
+			logger.Errf("webhook %s to %s failed after %d attempts: %v", m.ID, m.URL, m.Attempts, sendErr)
+		} else {
+			m.NextAttemptAt = wh.now().UTC().Add(Backoff(m.Attempts, wh.backoffBase(), wh.backoffMax()))
+		}
+	}
+
+	if err := wh.Repo.AddWebhookDelivery(ctx, delivery); err != nil {
+		return ok, err
+	}
+	if err := wh.Repo.UpdateWebhookMessage(ctx, m); err != nil {
+		return ok, err
+	}
+
+	if m.EndpointID != nil {
+		disabled, err := wh.Repo.RecordWebhookEndpointResult(ctx, *m.EndpointID, ok, wh.Config.Webhook.DisableAfter)
+		if err != nil {
+			return ok, err
+		}
+		if disabled && !ok {
+			logger.Warn(fmt.Sprintf("webhook endpoint %s is disabled after repeated failures", *m.EndpointID))
+		}
+	}
+
+	return ok, nil
+}
+
+func (wh *Webhook) post(ctx context.Context, m *models.WebhookMessage) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, wh.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(m.Payload))
+	if err != nil {
+		return 0, errs.WithMessage(err, "failed to create request")
+	}
+
+	secret := m.Secret
+	if secret == "" {
+		secret = wh.Config.Webhook.Secret
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, m.Event)
+	req.Header.Set(DeliveryHeader, m.ID.String())
+	req.Header.Set(SignatureHeader, Sign(secret, wh.now(), m.Payload))
+
+	res, err := wh.Client.Do(req)
+	if err != nil {
+		return 0, errs.WithMessage(err, "failed to do request")
+	}
+	defer func(Body io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, Body)
+		err = Body.Close()
+		if err != nil {
+			logger.Errf("failed to close response body: %v", err)
+			return
+		}
+	}(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("invalid response status: %v", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errs.WithMessage(err, "failed to generate webhook secret")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (wh *Webhook) pollInterval() time.Duration {
+	return orDefault(wh.Config.Webhook.PollInterval, defaultPollInterval)
+}
+
+func (wh *Webhook) timeout() time.Duration {
+	return orDefault(wh.Config.Webhook.Timeout, defaultTimeout)
+}
+
+func (wh *Webhook) backoffBase() time.Duration {
+	return orDefault(wh.Config.Webhook.BackoffBase, defaultBackoffBase)
+}
+
+func (wh *Webhook) backoffMax() time.Duration {
+	return orDefault(wh.Config.Webhook.BackoffMax, defaultBackoffMax)
+}
+
+func (wh *Webhook) maxAttempts() int {
+	return orDefault(wh.Config.Webhook.MaxAttempts, defaultMaxAttempts)
+}
+
+func (wh *Webhook) batchSize() int {
+	return orDefault(wh.Config.Webhook.BatchSize, defaultBatchSize)
+}
+
+func orDefault[T int | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,335 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/pkg/models"
+	mock_repository "github.com/mashmorsik/quotation/test/testdata/mock_repo"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Date(2024, 4, 11, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"quote.updated"}`)
+	header := Sign("secret", now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "valid", secret: "secret", header: header, body: body, now: now},
+		{name: "wrong_secret", secret: "other", header: header, body: body, now: now, wantErr: true},
+		{name: "tampered_body", secret: "secret", header: header, body: []byte(`{}`), now: now, wantErr: true},
+		{name: "expired", secret: "secret", header: header, body: body, now: now.Add(time.Hour), wantErr: true},
+		{name: "malformed", secret: "secret", header: "v1=abc", body: body, now: now, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt, 10*time.Second, time.Hour); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func newTestWebhook(repo *mock_repository.MockWebhookRepository, now time.Time) *Webhook {
+	conf := &config.Config{}
+	conf.Webhook.Secret = "global-secret"
+	conf.Webhook.MaxAttempts = 3
+	conf.Webhook.BackoffBase = time.Minute
+	conf.Webhook.BackoffMax = time.Hour
+	conf.Webhook.DisableAfter = 2
+	// the receivers are httptest servers on loopback
+	conf.Webhook.AllowPrivateNetworks = true
+
+	wh := NewWebhook(context.Background(), repo, conf)
+	wh.now = func() time.Time { return now }
+	return wh
+}
+
+func TestWebhook_DeliverPending(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Now().UTC()
+
+	var gotBody []byte
+	var gotHeader http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockWebhookRepository(ctrl)
+	wh := newTestWebhook(repo, now)
+
+	var queued *models.WebhookMessage
+	repo.EXPECT().AddWebhookMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m *models.WebhookMessage) error {
+		queued = m
+		return nil
+	})
+
+	if err := wh.Send(context.Background(), receiver.URL, EventQuoteUpdated, map[string]string{"quote": "EUR/USD"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// the lease covers posting every message of a full batch in turn
+	repo.EXPECT().ClaimDueWebhookMessages(gomock.Any(), now, now.Add(defaultBatchSize*defaultTimeout), defaultBatchSize).
+		Return([]*models.WebhookMessage{queued}, nil)
+	repo.EXPECT().AddWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, d *models.WebhookDelivery) error {
+		if d.StatusCode != http.StatusNoContent || d.Attempt != 1 || d.Error != "" {
+			t.Errorf("unexpected delivery: %+v", d)
+		}
+		return nil
+	})
+	repo.EXPECT().UpdateWebhookMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m *models.WebhookMessage) error {
+		if m.Status != models.WebhookStatusDelivered {
+			t.Errorf("unexpected status: %s", m.Status)
+		}
+		return nil
+	})
+
+	delivered, err := wh.DeliverPending(context.Background())
+	if err != nil || delivered != 1 {
+		t.Fatalf("DeliverPending() = %v, %v, want 1, nil", delivered, err)
+	}
+
+	if gotHeader.Get(EventHeader) != EventQuoteUpdated || gotHeader.Get(DeliveryHeader) != queued.ID.String() {
+		t.Errorf("unexpected headers: %v", gotHeader)
+	}
+	if err = Verify("global-secret", gotHeader.Get(SignatureHeader), gotBody, time.Minute, now); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	var envelope models.WebhookEnvelope
+	if err = json.Unmarshal(gotBody, &envelope); err != nil {
+		t.Fatalf("failed to unmarshal body: %v", err)
+	}
+	if envelope.Event != EventQuoteUpdated || string(envelope.Data) != `{"quote":"EUR/USD"}` {
+		t.Errorf("unexpected envelope: %+v", envelope)
+	}
+}
+
+func TestWebhook_DeliverPending_failure(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	now := time.Now().UTC()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockWebhookRepository(ctrl)
+	wh := newTestWebhook(repo, now)
+
+	endpointID := uuid.New()
+	tests := []struct {
+		name       string
+		attempts   int
+		wantStatus string
+		wantNext   time.Time
+	}{
+		{name: "retry_with_backoff", attempts: 1, wantStatus: models.WebhookStatusPending, wantNext: now.Add(2 * time.Minute)},
+		{name: "give_up", attempts: 2, wantStatus: models.WebhookStatusFailed, wantNext: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &models.WebhookMessage{
+				ID:            uuid.New(),
+				EndpointID:    &endpointID,
+				URL:           receiver.URL,
+				Secret:        "endpoint-secret",
+				Event:         EventAlertFired,
+				Payload:       []byte(`{}`),
+				Attempts:      tt.attempts,
+				Status:        models.WebhookStatusPending,
+				NextAttemptAt: now,
+			}
+
+			repo.EXPECT().ClaimDueWebhookMessages(gomock.Any(), now, gomock.Any(), defaultBatchSize).
+				Return([]*models.WebhookMessage{m}, nil)
+			repo.EXPECT().AddWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, d *models.WebhookDelivery) error {
+				if d.StatusCode != http.StatusInternalServerError || d.Error == "" {
+					t.Errorf("unexpected delivery: %+v", d)
+				}
+				return nil
+			})
+			repo.EXPECT().UpdateWebhookMessage(gomock.Any(), m).Return(nil)
+			repo.EXPECT().RecordWebhookEndpointResult(gomock.Any(), endpointID, false, 2).Return(tt.attempts > 1, nil)
+
+			delivered, err := wh.DeliverPending(context.Background())
+			if err != nil || delivered != 0 {
+				t.Fatalf("DeliverPending() = %v, %v, want 0, nil", delivered, err)
+			}
+			if m.Status != tt.wantStatus || !m.NextAttemptAt.Equal(tt.wantNext) {
+				t.Errorf("message status = %s, next = %v, want %s, %v", m.Status, m.NextAttemptAt,
+					tt.wantStatus, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestWebhook_DeliverPending_lease(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	start := time.Now().UTC()
+	var mu sync.Mutex
+	now := start
+
+	// every delivery takes 1.5s of the 2s lease
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		now = now.Add(1500 * time.Millisecond)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mock_repository.NewMockWebhookRepository(ctrl)
+	wh := newTestWebhook(repo, start)
+	wh.Config.Webhook.BatchSize = 2
+	wh.Config.Webhook.Timeout = time.Second
+	wh.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	messages := make([]*models.WebhookMessage, 2)
+	for i := range messages {
+		messages[i] = &models.WebhookMessage{ID: uuid.New(), URL: receiver.URL, Event: EventQuoteUpdated,
+			Payload: []byte(`{}`), Status: models.WebhookStatusPending, NextAttemptAt: start}
+	}
+
+	repo.EXPECT().ClaimDueWebhookMessages(gomock.Any(), start, start.Add(2*time.Second), 2).Return(messages, nil)
+	// the second message could outlive the lease, so it is left for the next claim
+	repo.EXPECT().AddWebhookDelivery(gomock.Any(), gomock.Any()).Return(nil)
+	repo.EXPECT().UpdateWebhookMessage(gomock.Any(), messages[0]).Return(nil)
+
+	delivered, err := wh.DeliverPending(context.Background())
+	if err != nil || delivered != 1 {
+		t.Fatalf("DeliverPending() = %v, %v, want 1, nil", delivered, err)
+	}
+	if messages[1].Attempts != 0 {
+		t.Errorf("second message attempts = %d, want 0", messages[1].Attempts)
+	}
+}
+
+func TestWebhook_ValidateURL(t *testing.T) {
+	hosts := map[string][]netip.Addr{
+		"client.example":   {netip.MustParseAddr("93.184.216.34")},
+		"metadata.example": {netip.MustParseAddr("169.254.169.254")},
+		"mixed.example":    {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.1")},
+	}
+	lookup := func(_ context.Context, host string) ([]netip.Addr, error) {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return []netip.Addr{addr}, nil
+		}
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return addrs, nil
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "public", url: "https://client.example/cb"},
+		{name: "public_ip", url: "http://93.184.216.34:8080/cb"},
+		{name: "empty", url: "", wantErr: true},
+		{name: "relative", url: "/cb", wantErr: true},
+		{name: "scheme", url: "ftp://client.example/cb", wantErr: true},
+		{name: "unresolved", url: "https://unknown.example/cb", wantErr: true},
+		{name: "metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "metadata_host", url: "http://metadata.example/", wantErr: true},
+		{name: "mixed_host", url: "https://mixed.example/cb", wantErr: true},
+		{name: "loopback", url: "http://127.0.0.1:8080/cb", wantErr: true},
+		{name: "loopback_v6", url: "http://[::1]/cb", wantErr: true},
+		{name: "mapped_v6", url: "http://[::ffff:127.0.0.1]/cb", wantErr: true},
+		{name: "private", url: "http://10.1.2.3/cb", wantErr: true},
+		{name: "unspecified", url: "http://0.0.0.0/cb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wh := NewWebhook(context.Background(), nil, &config.Config{})
+			wh.lookup = lookup
+
+			err := wh.ValidateURL(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhook_newClient(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	wh := NewWebhook(context.Background(), nil, &config.Config{})
+	_, err := wh.Client.Get(receiver.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Get() error = %v, want %v", err, ErrForbiddenAddress)
+	}
+
+	conf := &config.Config{}
+	conf.Webhook.AllowPrivateNetworks = true
+	wh = NewWebhook(context.Background(), nil, conf)
+	resp, err := wh.Client.Get(receiver.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Get() status = %d, want the redirect not followed", resp.StatusCode)
+	}
+}
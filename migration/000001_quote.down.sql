@@ -0,0 +1,2 @@
+drop table if exists public.quotation;
+drop table if exists public.quote_pair;
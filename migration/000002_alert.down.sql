@@ -0,0 +1,2 @@
+drop table if exists public.alert_firing;
+drop table if exists public.alert_rule;
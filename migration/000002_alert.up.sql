@@ -0,0 +1,26 @@
+create table if not exists public.alert_rule
+(
+    id uuid primary key,
+    base_currency text not null,
+    target_currency text not null,
+    comparator text not null,
+    threshold numeric not null,
+    change_window_seconds bigint not null default 0,
+    cooldown_seconds bigint not null default 0,
+    enabled boolean not null default true,
+    created_at timestamp with time zone not null,
+    last_fired_at timestamp with time zone
+);
+
+create index if not exists alert_rule_pair_idx
+    on public.alert_rule (base_currency, target_currency);
+
+create table if not exists public.alert_firing
+(
+    id uuid primary key,
+    rule_id uuid not null references public.alert_rule (id) on delete cascade,
+    quote_id uuid not null,
+    value numeric not null,
+    fired_at timestamp with time zone not null,
+    unique (rule_id, quote_id)
+);
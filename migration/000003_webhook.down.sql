@@ -0,0 +1,3 @@
+drop table if exists public.webhook_delivery;
+drop table if exists public.webhook_outbox;
+drop table if exists public.webhook_endpoint;
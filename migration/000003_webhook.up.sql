@@ -0,0 +1,47 @@
+create table if not exists public.webhook_endpoint
+(
+    id uuid primary key,
+    url text not null,
+    secret text not null,
+    events text[] not null default '{}',
+    enabled boolean not null default true,
+    consecutive_failures integer not null default 0,
+    created_at timestamp with time zone not null,
+    disabled_at timestamp with time zone
+);
+
+create table if not exists public.webhook_outbox
+(
+    id uuid primary key,
+    endpoint_id uuid references public.webhook_endpoint (id) on delete cascade,
+    url text not null,
+    secret text not null default '',
+    event text not null,
+    payload jsonb not null,
+    attempts integer not null default 0,
+    status text not null default 'pending',
+    next_attempt_at timestamp with time zone not null,
+    last_error text not null default '',
+    created_at timestamp with time zone not null
+);
+
+create index if not exists webhook_outbox_due_idx
+    on public.webhook_outbox (next_attempt_at)
+    where status = 'pending';
+
+create table if not exists public.webhook_delivery
+(
+    id uuid primary key,
+    message_id uuid not null references public.webhook_outbox (id) on delete cascade,
+    endpoint_id uuid references public.webhook_endpoint (id) on delete cascade,
+    url text not null,
+    event text not null,
+    attempt integer not null,
+    status_code integer not null default 0,
+    error text not null default '',
+    duration_ms bigint not null default 0,
+    delivered_at timestamp with time zone not null
+);
+
+create index if not exists webhook_delivery_endpoint_idx
+    on public.webhook_delivery (endpoint_id, delivered_at desc);
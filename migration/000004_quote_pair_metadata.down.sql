@@ -0,0 +1,5 @@
+alter table public.quote_pair
+    drop column if exists last_error,
+    drop column if exists last_fetched_at,
+    drop column if exists created_at,
+    drop column if exists enabled;
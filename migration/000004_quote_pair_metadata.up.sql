@@ -0,0 +1,5 @@
+alter table public.quote_pair
+    add column if not exists enabled boolean not null default true,
+    add column if not exists created_at timestamp with time zone not null default now(),
+    add column if not exists last_fetched_at timestamp with time zone,
+    add column if not exists last_error text not null default '';
@@ -0,0 +1,2 @@
+alter table public.quote_pair
+    drop column if exists schedule;
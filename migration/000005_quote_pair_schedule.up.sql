@@ -0,0 +1,2 @@
+alter table public.quote_pair
+    add column if not exists schedule text not null default '';
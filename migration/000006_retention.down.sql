@@ -0,0 +1,3 @@
+drop index if exists public.quotation_time_updated_idx;
+drop table if exists public.quotation_daily;
+drop table if exists public.quotation_hourly;
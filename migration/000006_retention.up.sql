@@ -0,0 +1,34 @@
+create table if not exists public.quotation_hourly
+(
+    base_currency text not null,
+    target_currency text not null,
+    bucket timestamp with time zone not null,
+    open numeric not null,
+    high numeric not null,
+    low numeric not null,
+    close numeric not null,
+    points integer not null,
+    primary key (base_currency, target_currency, bucket)
+);
+
+create index if not exists quotation_hourly_bucket_idx
+    on public.quotation_hourly (bucket);
+
+create table if not exists public.quotation_daily
+(
+    base_currency text not null,
+    target_currency text not null,
+    bucket timestamp with time zone not null,
+    open numeric not null,
+    high numeric not null,
+    low numeric not null,
+    close numeric not null,
+    points integer not null,
+    primary key (base_currency, target_currency, bucket)
+);
+
+create index if not exists quotation_daily_bucket_idx
+    on public.quotation_daily (bucket);
+
+create index if not exists quotation_time_updated_idx
+    on public.quotation (time_updated);
@@ -0,0 +1,2 @@
+drop table if exists public.api_key_usage;
+drop table if exists public.api_key;
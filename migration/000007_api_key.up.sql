@@ -0,0 +1,18 @@
+create table if not exists public.api_key
+(
+    id uuid primary key,
+    name text not null,
+    prefix text not null,
+    key_hash text not null unique,
+    daily_quota integer not null default 0,
+    created_at timestamp with time zone not null,
+    revoked_at timestamp with time zone
+);
+
+create table if not exists public.api_key_usage
+(
+    key_id uuid not null references public.api_key (id) on delete cascade,
+    day date not null,
+    requests bigint not null default 0,
+    primary key (key_id, day)
+);
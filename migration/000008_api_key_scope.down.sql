@@ -0,0 +1,2 @@
+alter table public.api_key
+    drop column if exists scopes;
@@ -0,0 +1,7 @@
+alter table public.api_key
+    add column if not exists scopes text[] not null default '{quotes:read}';
+
+-- keys issued before scopes existed could call every endpoint except the
+-- admin ones, keep it that way
+update public.api_key
+set scopes = '{quotes:read,quotes:write}';
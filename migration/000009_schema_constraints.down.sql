@@ -0,0 +1,5 @@
+alter table public.quotation
+    drop constraint if exists quotation_pair_fkey;
+drop index if exists public.quotation_pair_time_updated_idx;
+alter table public.quote_pair
+    drop constraint if exists quote_pair_base_target_key;
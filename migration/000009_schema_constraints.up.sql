@@ -0,0 +1,26 @@
+-- AddQuotePair used to check and then insert, so concurrent calls could
+-- track a pair twice; keep the oldest row of every pair
+delete from public.quote_pair qp
+    using public.quote_pair older
+where older.base_currency = qp.base_currency
+  and older.target_currency = qp.target_currency
+  and older.id < qp.id;
+
+alter table public.quote_pair
+    add constraint quote_pair_base_target_key unique (base_currency, target_currency);
+
+create index if not exists quotation_pair_time_updated_idx
+    on public.quotation (base_currency, target_currency, time_updated);
+
+-- quotations of pairs deleted before this migration get their pair back,
+-- disabled so the scheduler does not start polling it
+insert into public.quote_pair (base_currency, target_currency, enabled)
+select distinct base_currency, target_currency, false
+from public.quotation
+on conflict (base_currency, target_currency) do nothing;
+
+-- restrict: quotations are history, a pair with quotations is removed by
+-- marking it, not by deleting the row
+alter table public.quotation
+    add constraint quotation_pair_fkey foreign key (base_currency, target_currency)
+        references public.quote_pair (base_currency, target_currency) on delete restrict;
@@ -0,0 +1,27 @@
+-- detached partitions are left alone, their rows are not restored
+create table public.quotation_unpartitioned
+(
+    id uuid primary key,
+    base_currency text not null,
+    target_currency text not null,
+    rate numeric not null,
+    time_updated timestamp with time zone not null
+);
+
+insert into public.quotation_unpartitioned (id, base_currency, target_currency, rate, time_updated)
+select id, base_currency, target_currency, rate, time_updated
+from public.quotation;
+
+drop table public.quotation;
+alter table public.quotation_unpartitioned
+    rename to quotation;
+alter index public.quotation_unpartitioned_pkey
+    rename to quotation_pkey;
+
+alter table public.quotation
+    add constraint quotation_pair_fkey foreign key (base_currency, target_currency)
+        references public.quote_pair (base_currency, target_currency) on delete restrict;
+create index if not exists quotation_pair_time_updated_idx
+    on public.quotation (base_currency, target_currency, time_updated);
+create index if not exists quotation_time_updated_idx
+    on public.quotation (time_updated);
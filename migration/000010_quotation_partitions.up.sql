@@ -0,0 +1,61 @@
+-- quotation becomes range partitioned by time_updated in monthly partitions
+-- named quotation_pYYYYMM, so retention can drop whole months. The primary
+-- key has to include the partition key; ids are random uuids, so (id,
+-- time_updated) is as unique in practice. Rows outside every monthly
+-- partition land in quotation_default until the retention job creates
+-- their month and moves them.
+alter table public.quotation
+    rename to quotation_unpartitioned;
+alter table public.quotation_unpartitioned
+    drop constraint if exists quotation_pair_fkey,
+    drop constraint if exists quotation_pkey;
+drop index if exists public.quotation_pair_time_updated_idx;
+drop index if exists public.quotation_time_updated_idx;
+
+create table public.quotation
+(
+    id uuid not null,
+    base_currency text not null,
+    target_currency text not null,
+    rate numeric not null,
+    time_updated timestamp with time zone not null,
+    primary key (id, time_updated),
+    constraint quotation_pair_fkey foreign key (base_currency, target_currency)
+        references public.quote_pair (base_currency, target_currency) on delete restrict
+) partition by range (time_updated);
+
+create index quotation_pair_time_updated_idx
+    on public.quotation (base_currency, target_currency, time_updated);
+create index quotation_time_updated_idx
+    on public.quotation (time_updated);
+
+create table public.quotation_default
+    partition of public.quotation default;
+
+-- every month with data plus three ahead, matching the default
+-- retention.partitionsAhead
+do
+$$
+    declare
+        start timestamp;
+    begin
+        for start in
+            select generate_series(
+                date_trunc('month', coalesce((select min(time_updated) from public.quotation_unpartitioned), now())
+                    at time zone 'UTC'),
+                date_trunc('month', now() at time zone 'UTC') + interval '3 months',
+                interval '1 month')
+            loop
+                execute format('create table public.%I partition of public.quotation for values from (%L) to (%L)',
+                               'quotation_p' || to_char(start, 'YYYYMM'),
+                               start at time zone 'UTC',
+                               (start + interval '1 month') at time zone 'UTC');
+            end loop;
+    end
+$$;
+
+insert into public.quotation (id, base_currency, target_currency, rate, time_updated)
+select id, base_currency, target_currency, rate, time_updated
+from public.quotation_unpartitioned;
+
+drop table public.quotation_unpartitioned;
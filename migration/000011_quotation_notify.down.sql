@@ -0,0 +1,2 @@
+drop trigger if exists quotation_notify on public.quotation;
+drop function if exists public.notify_quotation();
@@ -0,0 +1,22 @@
+-- every inserted quotation is announced on the quotation channel, so other
+-- instances see it at once, see data.Listener. The trigger on the
+-- partitioned table is cloned to every partition, present and future.
+create or replace function public.notify_quotation() returns trigger
+    language plpgsql as
+$$
+begin
+    perform pg_notify('quotation', json_build_object(
+            'id', new.id,
+            'base_currency', new.base_currency,
+            'target_currency', new.target_currency,
+            'timestamp', new.time_updated,
+            'rate', new.rate)::text);
+    return null;
+end;
+$$;
+
+create trigger quotation_notify
+    after insert
+    on public.quotation
+    for each row
+execute function public.notify_quotation();
@@ -0,0 +1,2 @@
+alter table public.alert_rule
+    drop column if exists matching;
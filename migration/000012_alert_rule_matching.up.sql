@@ -0,0 +1,4 @@
+-- whether the last evaluated quote matched the rule; a rule fires when this
+-- turns true, not on every quote past its threshold
+alter table public.alert_rule
+    add column if not exists matching boolean not null default false;
@@ -0,0 +1,10 @@
+delete from public.quote_pair
+where removed_at is not null
+  and not exists (
+    select 1
+    from public.quotation q
+    where q.base_currency = quote_pair.base_currency
+      and q.target_currency = quote_pair.target_currency);
+
+alter table public.quote_pair
+    drop column if exists removed_at;
@@ -0,0 +1,4 @@
+-- DELETE /pairs keeps the row as a tombstone, so /update does not start
+-- tracking a removed pair again; only POST /pairs does
+alter table public.quote_pair
+    add column if not exists removed_at timestamp with time zone;
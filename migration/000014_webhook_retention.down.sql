@@ -0,0 +1,2 @@
+drop index if exists public.webhook_delivery_delivered_at_idx;
+drop index if exists public.webhook_outbox_finished_idx;
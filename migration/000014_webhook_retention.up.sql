@@ -0,0 +1,7 @@
+-- retention prunes finished messages and the delivery log by age
+create index if not exists webhook_outbox_finished_idx
+    on public.webhook_outbox (created_at)
+    where status <> 'pending';
+
+create index if not exists webhook_delivery_delivered_at_idx
+    on public.webhook_delivery (delivered_at);
@@ -0,0 +1,15 @@
+// Package migration embeds the SQL migrations so the binary can apply them
+// from any working directory.
+package migration
+
+import "embed"
+
+// Postgres holds the Postgres migrations at its root.
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+// SQLite holds the SQLite migrations under sqlite/.
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
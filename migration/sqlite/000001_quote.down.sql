@@ -0,0 +1,2 @@
+drop table if exists quotation;
+drop table if exists quote_pair;
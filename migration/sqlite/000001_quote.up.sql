@@ -0,0 +1,23 @@
+-- mirrors migration/000001_quote plus the quote_pair columns added in
+-- 000004 and 000005; sqlite cannot add a column defaulting to
+-- current_timestamp later, so they are created up front
+create table if not exists quotation
+(
+    id text primary key,
+    base_currency text not null,
+    target_currency text not null,
+    rate text not null,
+    time_updated timestamp not null
+);
+
+create table if not exists quote_pair
+(
+    id integer primary key autoincrement,
+    base_currency text not null,
+    target_currency text not null,
+    enabled boolean not null default true,
+    created_at timestamp not null default current_timestamp,
+    last_fetched_at timestamp,
+    last_error text not null default '',
+    schedule text not null default ''
+);
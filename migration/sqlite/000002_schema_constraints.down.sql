@@ -0,0 +1,17 @@
+create table quotation_old
+(
+    id text primary key,
+    base_currency text not null,
+    target_currency text not null,
+    rate text not null,
+    time_updated timestamp not null
+);
+
+insert into quotation_old
+select id, base_currency, target_currency, rate, time_updated
+from quotation;
+
+drop table quotation;
+alter table quotation_old rename to quotation;
+
+drop index if exists quote_pair_base_target_key;
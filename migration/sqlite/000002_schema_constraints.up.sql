@@ -0,0 +1,39 @@
+-- mirrors migration/000009_schema_constraints; sqlite cannot add a foreign
+-- key to an existing table, so quotation is rebuilt with one
+delete from quote_pair
+where exists (
+    select 1
+    from quote_pair older
+    where older.base_currency = quote_pair.base_currency
+      and older.target_currency = quote_pair.target_currency
+      and older.id < quote_pair.id);
+
+create unique index if not exists quote_pair_base_target_key
+    on quote_pair (base_currency, target_currency);
+
+insert into quote_pair (base_currency, target_currency, enabled)
+select distinct base_currency, target_currency, false
+from quotation
+where true
+on conflict (base_currency, target_currency) do nothing;
+
+create table quotation_new
+(
+    id text primary key,
+    base_currency text not null,
+    target_currency text not null,
+    rate text not null,
+    time_updated timestamp not null,
+    foreign key (base_currency, target_currency)
+        references quote_pair (base_currency, target_currency) on delete restrict
+);
+
+insert into quotation_new
+select id, base_currency, target_currency, rate, time_updated
+from quotation;
+
+drop table quotation;
+alter table quotation_new rename to quotation;
+
+create index if not exists quotation_pair_time_updated_idx
+    on quotation (base_currency, target_currency, time_updated);
@@ -0,0 +1,10 @@
+delete from quote_pair
+where removed_at is not null
+  and not exists (
+    select 1
+    from quotation q
+    where q.base_currency = quote_pair.base_currency
+      and q.target_currency = quote_pair.target_currency);
+
+alter table quote_pair
+    drop column removed_at;
@@ -0,0 +1,3 @@
+-- mirrors migration/000013_quote_pair_removed
+alter table quote_pair
+    add column removed_at timestamp;
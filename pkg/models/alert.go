@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type AlertRule struct {
+	ID             uuid.UUID       `json:"id"`
+	BaseCurrency   string          `json:"base_currency"`
+	TargetCurrency string          `json:"target_currency"`
+	Comparator     string          `json:"comparator"`
+	Threshold      decimal.Decimal `json:"threshold"`
+	ChangeWindow   Duration        `json:"change_window"`
+	Cooldown       Duration        `json:"cooldown"`
+	Enabled        bool            `json:"enabled"`
+	CreatedAt      time.Time       `json:"created_at"`
+	LastFiredAt    *time.Time      `json:"last_fired_at"`
+	// Matching reports whether the last evaluated quote matched, so the
+	// next firing needs it to stop matching first.
+	Matching bool `json:"matching"`
+}
+
+type AlertRuleRequest struct {
+	Quote        string          `json:"quote"`
+	Comparator   string          `json:"comparator"`
+	Threshold    decimal.Decimal `json:"threshold"`
+	ChangeWindow Duration        `json:"change_window"`
+	Cooldown     Duration        `json:"cooldown"`
+	Enabled      *bool           `json:"enabled"`
+}
+
+type AlertFiring struct {
+	ID      uuid.UUID       `json:"id"`
+	RuleID  uuid.UUID       `json:"rule_id"`
+	QuoteID uuid.UUID       `json:"quote_id"`
+	Value   decimal.Decimal `json:"value"`
+	FiredAt time.Time       `json:"fired_at"`
+}
+
+type AlertEvent struct {
+	Rule   *AlertRule   `json:"rule"`
+	Firing *AlertFiring `json:"firing"`
+	Quote  *Quote       `json:"quote"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ScopeQuotesRead  = "quotes:read"
+	ScopeQuotesWrite = "quotes:write"
+	ScopeAdmin       = "admin"
+)
+
+// Scopes lists every scope a key can be granted. ScopeAdmin implies all
+// others.
+var Scopes = []string{ScopeQuotesRead, ScopeQuotesWrite, ScopeAdmin}
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	DailyQuota int        `json:"daily_quota"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// HasScope reports whether the key was granted scope, directly or through
+// ScopeAdmin.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyUsage struct {
+	KeyID    uuid.UUID `json:"key_id"`
+	Day      time.Time `json:"day"`
+	Requests int64     `json:"requests"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// CacheCounters reports one repository read cache.
+type CacheCounters struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// CacheStats reports the repository read caches.
+type CacheStats struct {
+	Latest CacheCounters `json:"latest"`
+	Quotes CacheCounters `json:"quotes"`
+	Pairs  CacheCounters `json:"pairs"`
+}
@@ -6,10 +6,51 @@ import (
 )
 
 type Pair struct {
-	Quote string `json:"quote"`
+	Quote       string `json:"quote"`
+	CallbackURL string `json:"callback_url,omitempty"`
 }
 
 type LatestResponse struct {
 	Rate        decimal.Decimal `json:"rate"`
 	LastUpdated time.Time       `json:"last_updated"`
 }
+
+type BaseLatestResponse struct {
+	Base  string                    `json:"base"`
+	Rates map[string]LatestResponse `json:"rates"`
+}
+
+const (
+	MatrixSourceDirect  = "direct"
+	MatrixSourceInverse = "inverse"
+	MatrixSourceCross   = "cross"
+)
+
+type MatrixCell struct {
+	Rate        decimal.Decimal `json:"rate"`
+	Source      string          `json:"source"`
+	Via         string          `json:"via,omitempty"`
+	LastUpdated time.Time       `json:"last_updated"`
+}
+
+type MatrixResponse struct {
+	Currencies []string                          `json:"currencies"`
+	Rates      map[string]map[string]*MatrixCell `json:"rates"`
+}
+
+type TrackedPair struct {
+	ID             int32      `json:"id"`
+	BaseCurrency   string     `json:"base_currency"`
+	TargetCurrency string     `json:"target_currency"`
+	Enabled        bool       `json:"enabled"`
+	Schedule       string     `json:"schedule"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastFetchedAt  *time.Time `json:"last_fetched_at"`
+	LastError      string     `json:"last_error"`
+}
+
+type TrackedPairRequest struct {
+	Quote    string  `json:"quote"`
+	Enabled  *bool   `json:"enabled"`
+	Schedule *string `json:"schedule"`
+}
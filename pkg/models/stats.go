@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type Stats struct {
+	Quote         string          `json:"quote"`
+	Window        string          `json:"window"`
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	Points        int             `json:"points"`
+	Min           decimal.Decimal `json:"min"`
+	Max           decimal.Decimal `json:"max"`
+	Mean          decimal.Decimal `json:"mean"`
+	TWAP          decimal.Decimal `json:"twap"`
+	Volatility    float64         `json:"volatility"`
+	ChangePercent decimal.Decimal `json:"change_percent"`
+}
@@ -5,3 +5,18 @@ import "github.com/google/uuid"
 type UpdateResponse struct {
 	QuoteID uuid.UUID `json:"quoteID"`
 }
+
+const (
+	CallbackStatusStored = "stored"
+	CallbackStatusFailed = "failed"
+)
+
+// UpdateCallback is posted to the callback URL of an update request.
+// QuoteID is only set when the quote was stored.
+type UpdateCallback struct {
+	QuoteID *uuid.UUID `json:"quoteID,omitempty"`
+	Pair    string     `json:"pair"`
+	Status  string     `json:"status"`
+	Quote   *Quote     `json:"quote,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
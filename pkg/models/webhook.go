@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusFailed    = "failed"
+)
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	CreatedAt           time.Time  `json:"created_at"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+}
+
+type WebhookEndpointRequest struct {
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// WebhookMessage is an outbox entry: one event addressed to one URL.
+type WebhookMessage struct {
+	ID            uuid.UUID
+	EndpointID    *uuid.UUID
+	URL           string
+	Secret        string
+	Event         string
+	Payload       []byte
+	Attempts      int
+	Status        string
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+// WebhookEnvelope is the body posted to receivers.
+type WebhookEnvelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID          uuid.UUID  `json:"id"`
+	MessageID   uuid.UUID  `json:"message_id"`
+	EndpointID  *uuid.UUID `json:"endpoint_id"`
+	URL         string     `json:"url"`
+	Event       string     `json:"event"`
+	Attempt     int        `json:"attempt"`
+	StatusCode  int        `json:"status_code"`
+	Error       string     `json:"error"`
+	Duration    Duration   `json:"duration"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+}
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/models"
+	errs "github.com/pkg/errors"
+	"time"
+)
+
+const alertRuleColumns = `id, base_currency, target_currency, comparator, threshold,
+		change_window_seconds, cooldown_seconds, enabled, created_at, last_fired_at, matching`
+
+type AlertRepo struct {
+	data     *data.Data
+	timeouts Timeouts
+}
+
+func NewAlertRepo(data *data.Data, conf *config.Config) *AlertRepo {
+	return &AlertRepo{data: data, timeouts: NewTimeouts(conf)}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAlertRule(row rowScanner) (*models.AlertRule, error) {
+	var r models.AlertRule
+	var changeWindow, cooldown int64
+	var lastFired sql.NullTime
+
+	err := row.Scan(&r.ID, &r.BaseCurrency, &r.TargetCurrency, &r.Comparator, &r.Threshold,
+		&changeWindow, &cooldown, &r.Enabled, &r.CreatedAt, &lastFired, &r.Matching)
+	if err != nil {
+		return nil, err
+	}
+
+	r.ChangeWindow = models.Duration(time.Duration(changeWindow) * time.Second)
+	r.Cooldown = models.Duration(time.Duration(cooldown) * time.Second)
+	if lastFired.Valid {
+		r.LastFiredAt = &lastFired.Time
+	}
+
+	return &r, nil
+}
+
+func (ar *AlertRepo) queryAlertRules(ctx context.Context, op, query string, args ...any) ([]*models.AlertRule, error) {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, op)
+	defer cancel()
+
+	rows, err := ar.data.Master().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var rules []*models.AlertRule
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		rules = append(rules, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return rules, nil
+}
+
+func (ar *AlertRepo) AddAlertRule(ctx context.Context, r *models.AlertRule) error {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "AddAlertRule")
+	defer cancel()
+
+	query := `
+		INSERT INTO alert_rule (id, base_currency, target_currency, comparator, threshold,
+			change_window_seconds, cooldown_seconds, enabled, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := ar.data.Master().ExecContext(ctx, query, r.ID, r.BaseCurrency, r.TargetCurrency, r.Comparator,
+		r.Threshold, int64(time.Duration(r.ChangeWindow).Seconds()), int64(time.Duration(r.Cooldown).Seconds()),
+		r.Enabled, r.CreatedAt)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to add alert rule: %s", r.ID)
+	}
+
+	return nil
+}
+
+func (ar *AlertRepo) GetAlertRule(ctx context.Context, id uuid.UUID) (*models.AlertRule, error) {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "GetAlertRule")
+	defer cancel()
+
+	r, err := scanAlertRule(ar.data.Master().QueryRowContext(ctx, `
+		SELECT `+alertRuleColumns+`
+		FROM alert_rule
+		WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errs.WithMessagef(err, "failed to get alert rule: %s", id)
+	}
+
+	return r, nil
+}
+
+func (ar *AlertRepo) GetAlertRules(ctx context.Context) ([]*models.AlertRule, error) {
+	return ar.queryAlertRules(ctx, "GetAlertRules", `
+		SELECT `+alertRuleColumns+`
+		FROM alert_rule
+		ORDER BY created_at`)
+}
+
+func (ar *AlertRepo) GetAlertRulesForPair(ctx context.Context, from, to string) ([]*models.AlertRule, error) {
+	return ar.queryAlertRules(ctx, "GetAlertRulesForPair", `
+		SELECT `+alertRuleColumns+`
+		FROM alert_rule
+		WHERE base_currency = $1 AND target_currency = $2 AND enabled
+		ORDER BY created_at`, from, to)
+}
+
+// UpdateAlertRule overwrites the mutable fields of a rule and re-arms it. It
+// returns sql.ErrNoRows if the rule does not exist.
+func (ar *AlertRepo) UpdateAlertRule(ctx context.Context, r *models.AlertRule) error {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "UpdateAlertRule")
+	defer cancel()
+
+	res, err := ar.data.Master().ExecContext(ctx, `
+		UPDATE alert_rule
+		SET base_currency = $2, target_currency = $3, comparator = $4, threshold = $5,
+			change_window_seconds = $6, cooldown_seconds = $7, enabled = $8, matching = false
+		WHERE id = $1`, r.ID, r.BaseCurrency, r.TargetCurrency, r.Comparator, r.Threshold,
+		int64(time.Duration(r.ChangeWindow).Seconds()), int64(time.Duration(r.Cooldown).Seconds()), r.Enabled)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to update alert rule: %s", r.ID)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "alert rule not found: %s", r.ID)
+	}
+
+	return nil
+}
+
+// DeleteAlertRule removes a rule together with its firings. It returns
+// sql.ErrNoRows if the rule does not exist.
+func (ar *AlertRepo) DeleteAlertRule(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "DeleteAlertRule")
+	defer cancel()
+
+	res, err := ar.data.Master().ExecContext(ctx, `DELETE FROM alert_rule WHERE id = $1`, id)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to delete alert rule: %s", id)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "alert rule not found: %s", id)
+	}
+
+	return nil
+}
+
+// SetAlertRuleMatching stores whether the rule matched the last evaluated
+// quote and reports whether that changed. Only one of concurrent evaluators
+// sees a change.
+func (ar *AlertRepo) SetAlertRuleMatching(ctx context.Context, id uuid.UUID, matching bool) (bool, error) {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "SetAlertRuleMatching")
+	defer cancel()
+
+	res, err := ar.data.Master().ExecContext(ctx, `
+		UPDATE alert_rule
+		SET matching = $2
+		WHERE id = $1 AND matching <> $2`, id, matching)
+	if err != nil {
+		return false, errs.WithMessagef(err, "failed to set matching for alert rule: %s", id)
+	}
+
+	ra, _ := res.RowsAffected()
+	return ra > 0, nil
+}
+
+// AddAlertFiring records a firing unless the rule is still cooling down or
+// has already fired for the same quote. It reports whether the firing was
+// recorded.
+func (ar *AlertRepo) AddAlertFiring(ctx context.Context, f *models.AlertFiring) (bool, error) {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "AddAlertFiring")
+	defer cancel()
+
+	tx, err := ar.data.Master().BeginTx(ctx, nil)
+	if err != nil {
+		return false, errs.WithMessage(err, "failed to begin transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE alert_rule
+		SET last_fired_at = $2
+		WHERE id = $1
+			AND (last_fired_at IS NULL OR last_fired_at + cooldown_seconds * interval '1 second' <= $2)`,
+		f.RuleID, f.FiredAt)
+	if err != nil {
+		return false, errs.WithMessagef(err, "failed to update last_fired_at for rule: %s", f.RuleID)
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return false, nil
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		INSERT INTO alert_firing (id, rule_id, quote_id, value, fired_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (rule_id, quote_id) DO NOTHING`, f.ID, f.RuleID, f.QuoteID, f.Value, f.FiredAt)
+	if err != nil {
+		return false, errs.WithMessagef(err, "failed to add alert firing for rule: %s", f.RuleID)
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return false, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, errs.WithMessage(err, "failed to commit transaction")
+	}
+
+	return true, nil
+}
+
+func (ar *AlertRepo) GetAlertFirings(ctx context.Context, ruleID uuid.UUID) ([]*models.AlertFiring, error) {
+	ctx, cancel := ar.timeouts.withTimeout(ctx, "GetAlertFirings")
+	defer cancel()
+
+	query := `
+		SELECT id, rule_id, quote_id, value, fired_at
+		FROM alert_firing
+		WHERE rule_id = $1
+		ORDER BY fired_at DESC`
+
+	rows, err := ar.data.Master().QueryContext(ctx, query, ruleID)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var firings []*models.AlertFiring
+	for rows.Next() {
+		var f models.AlertFiring
+		if err = rows.Scan(&f.ID, &f.RuleID, &f.QuoteID, &f.Value, &f.FiredAt); err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		firings = append(firings, &f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return firings, nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/models"
+	errs "github.com/pkg/errors"
+	"time"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, daily_quota, scopes, created_at, revoked_at`
+
+type APIKeyRepo struct {
+	data     *data.Data
+	timeouts Timeouts
+}
+
+func NewAPIKeyRepo(data *data.Data, conf *config.Config) *APIKeyRepo {
+	return &APIKeyRepo{data: data, timeouts: NewTimeouts(conf)}
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &k.DailyQuota, pq.Array(&k.Scopes), &k.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+
+	return &k, nil
+}
+
+func (kr *APIKeyRepo) AddAPIKey(ctx context.Context, k *models.APIKey) error {
+	ctx, cancel := kr.timeouts.withTimeout(ctx, "AddAPIKey")
+	defer cancel()
+
+	_, err := kr.data.Master().ExecContext(ctx, `
+		INSERT INTO api_key (id, name, prefix, key_hash, daily_quota, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, k.ID, k.Name, k.Prefix, k.KeyHash, k.DailyQuota, pq.Array(k.Scopes),
+		k.CreatedAt)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to add api key: %s", k.Name)
+	}
+
+	return nil
+}
+
+func (kr *APIKeyRepo) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, cancel := kr.timeouts.withTimeout(ctx, "GetAPIKeyByHash")
+	defer cancel()
+
+	k, err := scanAPIKey(kr.data.Master().QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_key
+		WHERE key_hash = $1`, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errs.WithMessage(err, "failed to get api key by hash")
+	}
+
+	return k, nil
+}
+
+func (kr *APIKeyRepo) GetAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	ctx, cancel := kr.timeouts.withTimeout(ctx, "GetAPIKeys")
+	defer cancel()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_key
+		ORDER BY created_at`
+
+	rows, err := kr.data.Master().QueryContext(ctx, query)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		keys = append(keys, k)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks a key as revoked. It returns sql.ErrNoRows if the key
+// does not exist or is already revoked.
+func (kr *APIKeyRepo) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := kr.timeouts.withTimeout(ctx, "RevokeAPIKey")
+	defer cancel()
+
+	res, err := kr.data.Master().ExecContext(ctx, `
+		UPDATE api_key
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to revoke api key: %s", id)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "active api key not found: %s", id)
+	}
+
+	return nil
+}
+
+// IncrementAPIKeyUsage counts one request for the key on day unless the
+// key already used its quota, in which case allowed is false and the
+// counter is left unchanged. A zero quota is unlimited.
+func (kr *APIKeyRepo) IncrementAPIKeyUsage(ctx context.Context, id uuid.UUID, day time.Time, quota int) (int64, bool, error) {
+	ctx, cancel := kr.timeouts.withTimeout(ctx, "IncrementAPIKeyUsage")
+	defer cancel()
+
+	var requests int64
+	err := kr.data.Master().QueryRowContext(ctx, `
+		INSERT INTO api_key_usage (key_id, day, requests)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (key_id, day) DO UPDATE
+		SET requests = api_key_usage.requests + 1
+		WHERE $3 = 0 OR api_key_usage.requests < $3
+		RETURNING requests`, id, day, quota).Scan(&requests)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return int64(quota), false, nil
+		}
+		return 0, false, errs.WithMessagef(err, "failed to increment usage for api key: %s", id)
+	}
+
+	return requests, true, nil
+}
+
+func (kr *APIKeyRepo) GetAPIKeyUsage(ctx context.Context, id uuid.UUID, since time.Time) ([]*models.APIKeyUsage, error) {
+	ctx, cancel := kr.timeouts.withTimeout(ctx, "GetAPIKeyUsage")
+	defer cancel()
+
+	query := `
+		SELECT key_id, day, requests
+		FROM api_key_usage
+		WHERE key_id = $1 AND day >= $2
+		ORDER BY day`
+
+	rows, err := kr.data.Master().QueryContext(ctx, query, id, since)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var usage []*models.APIKeyUsage
+	for rows.Next() {
+		var u models.APIKeyUsage
+		if err = rows.Scan(&u.KeyID, &u.Day, &u.Requests); err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		usage = append(usage, &u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return usage, nil
+}
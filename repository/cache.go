@@ -0,0 +1,340 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCacheSize bounds every cache of CachedRepo when no size is set.
+const defaultCacheSize = 10000
+
+// CachedRepo is a Repository keeping hot reads in process memory: the latest
+// quote of every pair and the list of pairs for a TTL, and quotes by ID,
+// which never change, until they are evicted. Writes made through it
+// invalidate what they touch. Quotes inserted by other processes are seen
+// late, at most by the TTL, unless HandleQuote is subscribed to a
+// data.Listener. Misses are read from the primary, so a lagging replica
+// cannot put a stale value in the cache for a whole TTL.
+type CachedRepo struct {
+	Repository
+	cache *repoCache
+	// tx collects invalidations while bound to a transaction, applied once
+	// it ends. Reads inside a transaction skip the cache.
+	tx *[]func()
+}
+
+type repoCache struct {
+	latest *lru[string, *models.Quote]
+	quotes *lru[uuid.UUID, *models.Quote]
+	pairs  *lru[struct{}, [][]string]
+	// gen is bumped by every invalidation. A read stores its result only if
+	// gen did not change meanwhile, so a read racing a write cannot put back
+	// what the write replaced.
+	gen atomic.Uint64
+}
+
+// NewCachedRepo wraps repo. A zero ttl keeps entries until they are evicted
+// or invalidated; size bounds the entries of each cache.
+func NewCachedRepo(repo Repository, ttl time.Duration, size int) *CachedRepo {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+
+	return &CachedRepo{
+		Repository: repo,
+		cache: &repoCache{
+			latest: newLRU[string, *models.Quote](size, ttl),
+			quotes: newLRU[uuid.UUID, *models.Quote](size, 0),
+			pairs:  newLRU[struct{}, [][]string](1, ttl),
+		},
+	}
+}
+
+// CacheStats returns the hit and miss counters of the caches.
+func (cr *CachedRepo) CacheStats() models.CacheStats {
+	return models.CacheStats{
+		Latest: cr.cache.latest.counters(),
+		Quotes: cr.cache.quotes.counters(),
+		Pairs:  cr.cache.pairs.counters(),
+	}
+}
+
+// InvalidatePair drops the cached latest quote of from/to.
+func (cr *CachedRepo) InvalidatePair(from, to string) {
+	cr.cache.gen.Add(1)
+	cr.cache.latest.remove(pairKey(from, to))
+}
+
+// InvalidatePairs drops the cached list of pairs.
+func (cr *CachedRepo) InvalidatePairs() {
+	cr.cache.gen.Add(1)
+	cr.cache.pairs.remove(struct{}{})
+}
+
+// HandleQuote drops the cached latest quote of the pair of q, inserted by
+// any instance. A nil q means inserts may have been missed, so every latest
+// quote is dropped.
+func (cr *CachedRepo) HandleQuote(q *models.Quote) {
+	if q != nil {
+		cr.InvalidatePair(q.BaseCurrency, q.TargetCurrency)
+		return
+	}
+
+	cr.cache.gen.Add(1)
+	cr.cache.latest.clear()
+}
+
+// invalidateDeletedPair drops the cached pairs and latest quote of from/to.
+// Its quotes by ID are kept, like the stored quotations they cache.
+func (cr *CachedRepo) invalidateDeletedPair(from, to string) {
+	cr.InvalidatePairs()
+	cr.InvalidatePair(from, to)
+}
+
+// invalidate runs fn now, or when the transaction ends inside WithTx. It is
+// also run after a rollback, which costs a miss and nothing else.
+func (cr *CachedRepo) invalidate(fn func()) {
+	if cr.tx != nil {
+		*cr.tx = append(*cr.tx, fn)
+		return
+	}
+	fn()
+}
+
+// AddQuotePair drops the cached list of pairs only if the pair was new, as
+// it is called for every /update.
+func (cr *CachedRepo) AddQuotePair(ctx context.Context, from, to string) (bool, error) {
+	added, err := cr.Repository.AddQuotePair(ctx, from, to)
+	if added {
+		cr.invalidate(cr.InvalidatePairs)
+	}
+	return added, err
+}
+
+func (cr *CachedRepo) GetQuotePairs(ctx context.Context) ([][]string, error) {
+	if cr.tx != nil {
+		return cr.Repository.GetQuotePairs(ctx)
+	}
+
+	if pairs, ok := cr.cache.pairs.get(struct{}{}); ok {
+		return copyPairs(pairs), nil
+	}
+
+	gen := cr.cache.gen.Load()
+	pairs, err := cr.Repository.GetQuotePairs(data.WithPrimary(ctx))
+	if err != nil {
+		return nil, err
+	}
+	cr.cache.pairs.put(struct{}{}, copyPairs(pairs), func() bool { return cr.cache.gen.Load() == gen })
+
+	return pairs, nil
+}
+
+func (cr *CachedRepo) AddQuotation(ctx context.Context, q *models.Quote) error {
+	err := cr.Repository.AddQuotation(ctx, q)
+	cr.invalidate(func() { cr.InvalidatePair(q.BaseCurrency, q.TargetCurrency) })
+	return err
+}
+
+func (cr *CachedRepo) AddQuotations(ctx context.Context, quotes []*models.Quote) error {
+	err := cr.Repository.AddQuotations(ctx, quotes)
+	cr.invalidate(func() {
+		for _, q := range quotes {
+			cr.InvalidatePair(q.BaseCurrency, q.TargetCurrency)
+		}
+	})
+	return err
+}
+
+func (cr *CachedRepo) GetQuotation(ctx context.Context, id uuid.UUID) (*models.Quote, error) {
+	if cr.tx != nil {
+		return cr.Repository.GetQuotation(ctx, id)
+	}
+
+	if q, ok := cr.cache.quotes.get(id); ok {
+		return copyQuote(q), nil
+	}
+
+	gen := cr.cache.gen.Load()
+	q, err := cr.Repository.GetQuotation(data.WithPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	cr.cache.quotes.put(id, copyQuote(q), func() bool { return cr.cache.gen.Load() == gen })
+
+	return q, nil
+}
+
+// GetLastUpdated caches the latest quote of a pair. Pairs without quotes
+// are not cached, they are about to get one.
+func (cr *CachedRepo) GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error) {
+	if cr.tx != nil {
+		return cr.Repository.GetLastUpdated(ctx, from, to)
+	}
+
+	key := pairKey(from, to)
+	if q, ok := cr.cache.latest.get(key); ok {
+		return copyQuote(q), nil
+	}
+
+	gen := cr.cache.gen.Load()
+	q, err := cr.Repository.GetLastUpdated(data.WithPrimary(ctx), from, to)
+	if err != nil || q == nil {
+		return q, err
+	}
+	cr.cache.latest.put(key, copyQuote(q), func() bool { return cr.cache.gen.Load() == gen })
+
+	return q, nil
+}
+
+func (cr *CachedRepo) SetQuotePairEnabled(ctx context.Context, from, to string, enabled bool) error {
+	err := cr.Repository.SetQuotePairEnabled(ctx, from, to, enabled)
+	cr.invalidate(cr.InvalidatePairs)
+	return err
+}
+
+func (cr *CachedRepo) RestoreQuotePair(ctx context.Context, from, to string) error {
+	err := cr.Repository.RestoreQuotePair(ctx, from, to)
+	cr.invalidate(cr.InvalidatePairs)
+	return err
+}
+
+func (cr *CachedRepo) DeleteQuotePair(ctx context.Context, from, to string) error {
+	err := cr.Repository.DeleteQuotePair(ctx, from, to)
+	cr.invalidate(func() { cr.invalidateDeletedPair(from, to) })
+	return err
+}
+
+// WithTx binds the wrapper to the transaction of the wrapped repository.
+// What fn writes is invalidated after the transaction ends.
+func (cr *CachedRepo) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	if cr.tx != nil {
+		return cr.Repository.WithTx(ctx, func(repo Repository) error {
+			return fn(&CachedRepo{Repository: repo, cache: cr.cache, tx: cr.tx})
+		})
+	}
+
+	var pending []func()
+	err := cr.Repository.WithTx(ctx, func(repo Repository) error {
+		return fn(&CachedRepo{Repository: repo, cache: cr.cache, tx: &pending})
+	})
+	for _, fn := range pending {
+		fn()
+	}
+
+	return err
+}
+
+func copyPairs(pairs [][]string) [][]string {
+	cp := make([][]string, len(pairs))
+	for i, p := range pairs {
+		cp[i] = append([]string(nil), p...)
+	}
+	return cp
+}
+
+// lru is a size bounded cache evicting the least recently used entry, with
+// entries expiring after ttl unless it is zero.
+type lru[K comparable, V any] struct {
+	mu     sync.Mutex
+	size   int
+	ttl    time.Duration
+	order  *list.List
+	items  map[K]*list.Element
+	now    func() time.Time
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func newLRU[K comparable, V any](size int, ttl time.Duration) *lru[K, V] {
+	return &lru[K, V]{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[K]*list.Element),
+		now:   time.Now,
+	}
+}
+
+func (c *lru[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok {
+		entry := el.Value.(*lruEntry[K, V])
+		if c.ttl <= 0 || c.now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			c.hits.Add(1)
+			return entry.value, true
+		}
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+
+	c.misses.Add(1)
+	var zero V
+	return zero, false
+}
+
+// put stores value if valid, checked under the lock, still holds.
+func (c *lru[K, V]) put(key K, value V, valid func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !valid() {
+		return
+	}
+
+	entry := &lruEntry[K, V]{key: key, value: value, expires: c.now().Add(c.ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+func (c *lru[K, V]) remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+func (c *lru[K, V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.items)
+}
+
+func (c *lru[K, V]) counters() models.CacheCounters {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return models.CacheCounters{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: entries}
+}
@@ -0,0 +1,196 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	"github.com/mashmorsik/quotation/repository/repotest"
+	"github.com/shopspring/decimal"
+	"testing"
+	"time"
+)
+
+func TestCachedRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.Repository {
+		return repository.NewCachedRepo(repository.NewMemoryRepo(), time.Minute, 0)
+	})
+}
+
+func TestCachedRepo_counters(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCachedRepo(repository.NewMemoryRepo(), time.Minute, 0)
+
+	if _, err := repo.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	first := &models.Quote{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD",
+		Rate: decimal.NewFromFloat(1.1), Timestamp: time.Now().Add(-time.Minute)}
+	if err := repo.AddQuotation(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got, err := repo.GetLastUpdated(ctx, "EUR", "USD"); err != nil || got.ID != first.ID {
+			t.Fatalf("GetLastUpdated() = %v, %v, want %s", got, err, first.ID)
+		}
+		if _, err := repo.GetQuotation(ctx, first.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := repo.CacheStats()
+	if stats.Latest.Hits != 2 || stats.Latest.Misses != 1 || stats.Quotes.Hits != 2 || stats.Quotes.Misses != 1 {
+		t.Fatalf("CacheStats() = %+v, want 2 hits and 1 miss each", stats)
+	}
+
+	// a write through the cache replaces the latest quote at once
+	second := &models.Quote{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD",
+		Rate: decimal.NewFromFloat(1.2), Timestamp: time.Now()}
+	err := repo.WithTx(ctx, func(tx repository.Repository) error {
+		return tx.AddQuotation(ctx, second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetLastUpdated(ctx, "EUR", "USD"); err != nil || got.ID != second.ID {
+		t.Fatalf("GetLastUpdated() after AddQuotation = %v, %v, want %s", got, err, second.ID)
+	}
+
+	// deleting the pair keeps its quotes, cached by ID too
+	if err = repo.DeleteQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.GetQuotation(ctx, first.ID); err != nil {
+		t.Fatalf("GetQuotation() of a deleted pair's quote error = %v", err)
+	}
+	if stats := repo.CacheStats(); stats.Quotes.Hits != 3 {
+		t.Errorf("CacheStats() = %+v, want the quote still cached", stats)
+	}
+}
+
+func TestCachedRepo_AddQuotePair(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewCachedRepo(repository.NewMemoryRepo(), time.Minute, 0)
+
+	if _, err := repo.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetQuotePairs(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// tracking a pair again, as every /update does, keeps the cached list
+	if _, err := repo.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetQuotePairs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := repo.CacheStats(); stats.Pairs.Hits != 1 || stats.Pairs.Misses != 1 {
+		t.Fatalf("CacheStats() = %+v, want 1 hit and 1 miss", stats.Pairs)
+	}
+
+	if _, err := repo.AddQuotePair(ctx, "USD", "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if pairs, err := repo.GetQuotePairs(ctx); err != nil || len(pairs) != 2 {
+		t.Fatalf("GetQuotePairs() after a new pair = %v, %v, want 2 pairs", pairs, err)
+	}
+}
+
+// staleReplica answers reads with what it was given unless they ask for the
+// primary, like a replica lagging behind.
+type staleReplica struct {
+	repository.Repository
+	latest *models.Quote
+	pairs  [][]string
+}
+
+func (r *staleReplica) GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error) {
+	if data.IsPrimary(ctx) {
+		return r.Repository.GetLastUpdated(ctx, from, to)
+	}
+	return r.latest, nil
+}
+
+func (r *staleReplica) GetQuotePairs(ctx context.Context) ([][]string, error) {
+	if data.IsPrimary(ctx) {
+		return r.Repository.GetQuotePairs(ctx)
+	}
+	return r.pairs, nil
+}
+
+func TestCachedRepo_fills_from_primary(t *testing.T) {
+	ctx := context.Background()
+	inner := repository.NewMemoryRepo()
+
+	if _, err := inner.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	first := &models.Quote{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD",
+		Rate: decimal.NewFromFloat(1.1), Timestamp: time.Now().Add(-time.Minute)}
+	if err := inner.AddQuotation(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.NewCachedRepo(&staleReplica{Repository: inner, latest: first}, time.Hour, 0)
+
+	second := &models.Quote{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD",
+		Rate: decimal.NewFromFloat(1.2), Timestamp: time.Now()}
+	if err := repo.AddQuotation(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := repo.GetLastUpdated(ctx, "EUR", "USD"); err != nil || got.ID != second.ID {
+			t.Fatalf("GetLastUpdated() = %v, %v, want %s, not the replica's", got, err, second.ID)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if pairs, err := repo.GetQuotePairs(ctx); err != nil || len(pairs) != 1 {
+			t.Fatalf("GetQuotePairs() = %v, %v, want EUR/USD, not the replica's", pairs, err)
+		}
+	}
+}
+
+func TestCachedRepo_HandleQuote(t *testing.T) {
+	ctx := context.Background()
+	inner := repository.NewMemoryRepo()
+	repo := repository.NewCachedRepo(inner, time.Hour, 0)
+
+	if _, err := inner.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+	first := &models.Quote{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD",
+		Rate: decimal.NewFromFloat(1.1), Timestamp: time.Now().Add(-time.Minute)}
+	if err := inner.AddQuotation(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetLastUpdated(ctx, "EUR", "USD"); err != nil {
+		t.Fatal(err)
+	}
+
+	// quotes inserted by another instance, behind the cache's back, are seen
+	// once notified; nil stands for notifications missed on reconnect
+	for i, withQuote := range []bool{true, false} {
+		q := &models.Quote{ID: uuid.New(), BaseCurrency: "EUR", TargetCurrency: "USD",
+			Rate: decimal.NewFromFloat(1.2), Timestamp: time.Now().Add(time.Duration(i) * time.Second)}
+		if err := inner.AddQuotation(ctx, q); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := repo.GetLastUpdated(ctx, "EUR", "USD"); got.ID == q.ID {
+			t.Fatalf("GetLastUpdated() = %s before the notification, want the cached quote", got.ID)
+		}
+
+		if withQuote {
+			repo.HandleQuote(q)
+		} else {
+			repo.HandleQuote(nil)
+		}
+		if got, _ := repo.GetLastUpdated(ctx, "EUR", "USD"); got.ID != q.ID {
+			t.Fatalf("GetLastUpdated() = %s after the notification, want %s", got.ID, q.ID)
+		}
+	}
+}
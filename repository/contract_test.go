@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/repository"
+	"github.com/mashmorsik/quotation/repository/repotest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// postgresDSNEnv names the variable holding the DSN of a disposable Postgres
+// database. The Postgres run is skipped when it is unset; its tables are
+// truncated before every subtest.
+const postgresDSNEnv = "QUOTATION_TEST_POSTGRES_DSN"
+
+func TestMemoryRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.Repository {
+		return repository.NewMemoryRepo()
+	})
+}
+
+func TestSQLiteRepo(t *testing.T) {
+	logger.BuildLogger(nil)
+
+	repotest.Run(t, func(t *testing.T) repository.Repository {
+		conn, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "quotation.db")+"?_pragma=foreign_keys(1)")
+		if err != nil {
+			t.Fatalf("failed to open sqlite: %v", err)
+		}
+		conn.SetMaxOpenConns(1)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		if err = data.MigrateSQLite(conn); err != nil {
+			t.Fatalf("MigrateSQLite() error = %v", err)
+		}
+
+		// the 1500 row batch of add_quotations is slow under -race
+		conf := &config.Config{}
+		conf.Storage.Timeouts = map[string]time.Duration{"AddQuotations": time.Minute}
+
+		return repository.NewSQLiteRepo(data.NewData(context.Background(), conn), conf)
+	})
+}
+
+func TestQuoteRepo_postgres(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	logger.BuildLogger(nil)
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// a second run has nothing to do; neither may keep a connection
+	for i := 0; i < 2; i++ {
+		if err = data.Migrate(conn); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+	if inUse := conn.Stats().InUse; inUse != 0 {
+		t.Fatalf("Migrate() left %d connections in use", inUse)
+	}
+
+	repotest.Run(t, func(t *testing.T) repository.Repository {
+		if _, err := conn.Exec(`TRUNCATE quotation, quote_pair RESTART IDENTITY CASCADE`); err != nil {
+			t.Fatalf("failed to truncate tables: %v", err)
+		}
+		return repository.NewQuoteRepo(data.NewData(context.Background(), conn), &config.Config{})
+	})
+}
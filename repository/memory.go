@@ -0,0 +1,441 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/pkg/models"
+	errs "github.com/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepo is a Repository kept in process memory. It mirrors QuoteRepo,
+// including sql.ErrNoRows for missing rows, so it can stand in for Postgres
+// in tests and local runs. Nothing survives a restart.
+type MemoryRepo struct {
+	// txMu serialises WithTx calls.
+	txMu  sync.Mutex
+	mu    sync.RWMutex
+	pairs []*models.TrackedPair
+	// removed holds the pairs removed with DeleteQuotePair per "base/target".
+	removed map[string]*models.TrackedPair
+	nextID  int32
+	quotes  map[uuid.UUID]*models.Quote
+	// byPair holds quotes per "base/target" ordered by time.
+	byPair map[string][]*models.Quote
+	now    func() time.Time
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{
+		removed: make(map[string]*models.TrackedPair),
+		quotes:  make(map[uuid.UUID]*models.Quote),
+		byPair:  make(map[string][]*models.Quote),
+		now:     time.Now,
+	}
+}
+
+func pairKey(from, to string) string {
+	return from + "/" + to
+}
+
+func copyQuote(q *models.Quote) *models.Quote {
+	c := *q
+	return &c
+}
+
+func copyTrackedPair(p *models.TrackedPair) *models.TrackedPair {
+	c := *p
+	if p.LastFetchedAt != nil {
+		t := *p.LastFetchedAt
+		c.LastFetchedAt = &t
+	}
+	return &c
+}
+
+// pair returns the stored pair; callers must hold mu.
+func (mr *MemoryRepo) pair(from, to string) (int, *models.TrackedPair) {
+	for i, p := range mr.pairs {
+		if p.BaseCurrency == from && p.TargetCurrency == to {
+			return i, p
+		}
+	}
+	return -1, nil
+}
+
+func (mr *MemoryRepo) AddQuotePair(ctx context.Context, from, to string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if _, p := mr.pair(from, to); p != nil {
+		return false, nil
+	}
+	if _, ok := mr.removed[pairKey(from, to)]; ok {
+		return false, errs.WithMessagef(ErrQuotePairRemoved, "%s/%s", from, to)
+	}
+
+	mr.nextID++
+	mr.pairs = append(mr.pairs, &models.TrackedPair{
+		ID:             mr.nextID,
+		BaseCurrency:   from,
+		TargetCurrency: to,
+		Enabled:        true,
+		CreatedAt:      mr.now().UTC(),
+	})
+
+	return true, nil
+}
+
+// GetQuotePairs returns the enabled pairs, i.e. the ones the scheduler polls.
+func (mr *MemoryRepo) GetQuotePairs(ctx context.Context) ([][]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var quotePairs [][]string
+	for _, p := range mr.pairs {
+		if p.Enabled {
+			quotePairs = append(quotePairs, []string{p.BaseCurrency, p.TargetCurrency})
+		}
+	}
+
+	return quotePairs, nil
+}
+
+// AddQuotation stores a quote. Like the quotation foreign key it rejects
+// quotes of pairs that are not tracked.
+func (mr *MemoryRepo) AddQuotation(ctx context.Context, q *models.Quote) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if err := mr.checkQuote(q); err != nil {
+		return errs.WithMessagef(err, "failed to add quote for quoteID: %s", q.ID)
+	}
+
+	mr.addQuote(q)
+	return nil
+}
+
+// AddQuotations stores all quotes or, returning a *BatchError with the ones
+// that cannot be stored, none of them.
+func (mr *MemoryRepo) AddQuotations(ctx context.Context, quotes []*models.Quote) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	var failed []FailedQuote
+	seen := make(map[uuid.UUID]bool, len(quotes))
+	for _, q := range quotes {
+		err := mr.checkQuote(q)
+		if err == nil && seen[q.ID] {
+			err = errs.New("duplicate id")
+		}
+		if err != nil {
+			failed = append(failed, FailedQuote{Quote: q, Err: err})
+		}
+		seen[q.ID] = true
+	}
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed}
+	}
+
+	for _, q := range quotes {
+		mr.addQuote(q)
+	}
+	return nil
+}
+
+// checkQuote applies the constraints of the quotation table; callers must
+// hold mu.
+func (mr *MemoryRepo) checkQuote(q *models.Quote) error {
+	if _, ok := mr.quotes[q.ID]; ok {
+		return errs.New("duplicate id")
+	}
+	_, removed := mr.removed[pairKey(q.BaseCurrency, q.TargetCurrency)]
+	if _, p := mr.pair(q.BaseCurrency, q.TargetCurrency); p == nil && !removed {
+		return errs.Errorf("pair %s/%s is not tracked", q.BaseCurrency, q.TargetCurrency)
+	}
+	return nil
+}
+
+// addQuote stores q in time order; callers must hold mu.
+func (mr *MemoryRepo) addQuote(q *models.Quote) {
+	stored := copyQuote(q)
+	mr.quotes[q.ID] = stored
+
+	key := pairKey(q.BaseCurrency, q.TargetCurrency)
+	series := mr.byPair[key]
+	i := sort.Search(len(series), func(i int) bool { return series[i].Timestamp.After(q.Timestamp) })
+	series = append(series, nil)
+	copy(series[i+1:], series[i:])
+	series[i] = stored
+	mr.byPair[key] = series
+}
+
+func (mr *MemoryRepo) GetQuotation(ctx context.Context, id uuid.UUID) (*models.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	q, ok := mr.quotes[id]
+	if !ok {
+		return nil, errs.WithMessagef(sql.ErrNoRows, "failed to get quote for id: %s", id)
+	}
+
+	return copyQuote(q), nil
+}
+
+func (mr *MemoryRepo) GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	series := mr.byPair[pairKey(from, to)]
+	if len(series) == 0 {
+		return nil, nil
+	}
+
+	return copyQuote(series[len(series)-1]), nil
+}
+
+func (mr *MemoryRepo) GetQuotationsSince(ctx context.Context, from, to string, since time.Time) ([]*models.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	series := mr.byPair[pairKey(from, to)]
+	i := sort.Search(len(series), func(i int) bool { return !series[i].Timestamp.Before(since) })
+
+	var quotes []*models.Quote
+	for _, q := range series[i:] {
+		quotes = append(quotes, copyQuote(q))
+	}
+
+	return quotes, nil
+}
+
+// GetLatestQuotes returns the freshest stored quote of every enabled pair,
+// of those with base as base currency unless base is empty.
+func (mr *MemoryRepo) GetLatestQuotes(ctx context.Context, base string) ([]*models.Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var quotes []*models.Quote
+	for _, p := range mr.pairs {
+		if !p.Enabled || (base != "" && p.BaseCurrency != base) {
+			continue
+		}
+		if series := mr.byPair[pairKey(p.BaseCurrency, p.TargetCurrency)]; len(series) > 0 {
+			quotes = append(quotes, copyQuote(series[len(series)-1]))
+		}
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		if quotes[i].BaseCurrency != quotes[j].BaseCurrency {
+			return quotes[i].BaseCurrency < quotes[j].BaseCurrency
+		}
+		return quotes[i].TargetCurrency < quotes[j].TargetCurrency
+	})
+
+	return quotes, nil
+}
+
+func (mr *MemoryRepo) GetTrackedPairs(ctx context.Context) ([]*models.TrackedPair, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var pairs []*models.TrackedPair
+	for _, p := range mr.pairs {
+		pairs = append(pairs, copyTrackedPair(p))
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].BaseCurrency != pairs[j].BaseCurrency {
+			return pairs[i].BaseCurrency < pairs[j].BaseCurrency
+		}
+		return pairs[i].TargetCurrency < pairs[j].TargetCurrency
+	})
+
+	return pairs, nil
+}
+
+func (mr *MemoryRepo) GetTrackedPair(ctx context.Context, from, to string) (*models.TrackedPair, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	_, p := mr.pair(from, to)
+	if p == nil {
+		return nil, nil
+	}
+
+	return copyTrackedPair(p), nil
+}
+
+// updatePair applies fn to a stored pair. It returns sql.ErrNoRows if the
+// pair is not tracked.
+func (mr *MemoryRepo) updatePair(ctx context.Context, from, to string, fn func(p *models.TrackedPair)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	_, p := mr.pair(from, to)
+	if p == nil {
+		return errs.WithMessagef(sql.ErrNoRows, "quote pair not found: %s/%s", from, to)
+	}
+
+	fn(p)
+	return nil
+}
+
+// SetQuotePairEnabled pauses or resumes polling of a pair. It returns
+// sql.ErrNoRows if the pair is not tracked.
+func (mr *MemoryRepo) SetQuotePairEnabled(ctx context.Context, from, to string, enabled bool) error {
+	return mr.updatePair(ctx, from, to, func(p *models.TrackedPair) {
+		p.Enabled = enabled
+	})
+}
+
+// SetQuotePairSchedule sets the refresh schedule of a pair. It returns
+// sql.ErrNoRows if the pair is not tracked.
+func (mr *MemoryRepo) SetQuotePairSchedule(ctx context.Context, from, to, schedule string) error {
+	return mr.updatePair(ctx, from, to, func(p *models.TrackedPair) {
+		p.Schedule = schedule
+	})
+}
+
+// DeleteQuotePair stops tracking a pair. Stored quotations are kept, and the
+// pair is remembered for AddQuotePair. It returns sql.ErrNoRows if the pair
+// is not tracked.
+func (mr *MemoryRepo) DeleteQuotePair(ctx context.Context, from, to string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	i, p := mr.pair(from, to)
+	if p == nil {
+		return errs.WithMessagef(sql.ErrNoRows, "quote pair not found: %s/%s", from, to)
+	}
+
+	mr.pairs = append(mr.pairs[:i], mr.pairs[i+1:]...)
+	p.Enabled = false
+	mr.removed[pairKey(from, to)] = p
+
+	return nil
+}
+
+// RestoreQuotePair tracks a removed pair again, enabled and with fresh
+// metadata. It returns sql.ErrNoRows if the pair was not removed.
+func (mr *MemoryRepo) RestoreQuotePair(ctx context.Context, from, to string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	key := pairKey(from, to)
+	p, ok := mr.removed[key]
+	if !ok {
+		return errs.WithMessagef(sql.ErrNoRows, "removed quote pair not found: %s/%s", from, to)
+	}
+
+	delete(mr.removed, key)
+	mr.pairs = append(mr.pairs, &models.TrackedPair{
+		ID:             p.ID,
+		BaseCurrency:   from,
+		TargetCurrency: to,
+		Enabled:        true,
+		CreatedAt:      mr.now().UTC(),
+	})
+
+	return nil
+}
+
+// RecordQuotePairFetch stores the outcome of a scheduled fetch. Like the
+// Postgres UPDATE it is a no-op for pairs that are not tracked.
+func (mr *MemoryRepo) RecordQuotePairFetch(ctx context.Context, from, to string, at time.Time, fetchErr string) error {
+	err := mr.updatePair(ctx, from, to, func(p *models.TrackedPair) {
+		p.LastFetchedAt = &at
+		p.LastError = fetchErr
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// WithTx runs fn with the repository itself, one call at a time. Changes fn
+// made before failing are not rolled back.
+func (mr *MemoryRepo) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mr.txMu.Lock()
+	defer mr.txMu.Unlock()
+
+	return fn(&memoryTx{mr})
+}
+
+// LockQuotePair fails outside WithTx, like QuoteRepo.
+func (mr *MemoryRepo) LockQuotePair(ctx context.Context, from, to string) error {
+	return errs.New("LockQuotePair needs a transaction, see WithTx")
+}
+
+// memoryTx is the MemoryRepo handed to a WithTx callback. WithTx calls are
+// already serialised, so locking a pair is a no-op and nested calls join.
+type memoryTx struct {
+	*MemoryRepo
+}
+
+func (tx *memoryTx) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	return fn(tx)
+}
+
+func (tx *memoryTx) LockQuotePair(ctx context.Context, from, to string) error {
+	return ctx.Err()
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/mashmorsik/logger"
+	errs "github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// partitionPrefix names the monthly quotation partitions, e.g.
+// quotation_p202405 for May 2024.
+const partitionPrefix = "quotation_p"
+
+func partitionName(month time.Time) string {
+	return partitionPrefix + month.UTC().Format("200601")
+}
+
+func monthBounds(month time.Time) (time.Time, time.Time) {
+	month = month.UTC()
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// QuotationPartitions returns the first day of every month that has a
+// quotation partition attached, in order.
+func (rr *RetentionRepo) QuotationPartitions(ctx context.Context) ([]time.Time, error) {
+	ctx, cancel := rr.timeouts.withTimeout(ctx, "QuotationPartitions")
+	defer cancel()
+
+	query := `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'public.quotation'::regclass
+		ORDER BY c.relname`
+
+	rows, err := rr.data.Master().QueryContext(ctx, query)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var months []time.Time
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, errs.WithMessage(err, "failed to scan partition name")
+		}
+
+		if !strings.HasPrefix(name, partitionPrefix) {
+			// quotation_default
+			continue
+		}
+		month, err := time.Parse("200601", strings.TrimPrefix(name, partitionPrefix))
+		if err != nil {
+			logger.Warn(fmt.Sprintf("skip quotation partition with unexpected name: %s", name))
+			continue
+		}
+		months = append(months, month)
+	}
+
+	return months, rows.Err()
+}
+
+// CreateQuotationPartition creates and attaches the partition of the month
+// that contains month. Rows of that month that landed in quotation_default
+// are moved into it, so attaching never fails on them.
+func (rr *RetentionRepo) CreateQuotationPartition(ctx context.Context, month time.Time) error {
+	ctx, cancel := rr.timeouts.withTimeout(ctx, "CreateQuotationPartition")
+	defer cancel()
+
+	name := partitionName(month)
+	start, end := monthBounds(month)
+	from, to := start.Format(time.RFC3339), end.Format(time.RFC3339)
+
+	tx, err := rr.data.Master().BeginTx(ctx, nil)
+	if err != nil {
+		return errs.WithMessage(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	statements := []string{
+		`CREATE TABLE public.` + name + ` (LIKE public.quotation INCLUDING DEFAULTS)`,
+		`WITH moved AS (
+			DELETE FROM public.quotation_default
+			WHERE time_updated >= '` + from + `' AND time_updated < '` + to + `'
+			RETURNING *)
+		INSERT INTO public.` + name + ` SELECT * FROM moved`,
+		`ALTER TABLE public.quotation ATTACH PARTITION public.` + name + ` FOR VALUES FROM ('` + from + `') TO ('` + to + `')`,
+	}
+	for _, query := range statements {
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			return errs.WithMessagef(err, "failed to exec query: %s", query)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errs.WithMessagef(err, "failed to create partition %s", name)
+	}
+
+	return nil
+}
+
+// RemoveQuotationPartition detaches or drops the partition of month. A
+// partition still holding the latest quotation of a pair is kept, like
+// DeleteRawBefore keeps that row, and false is returned.
+func (rr *RetentionRepo) RemoveQuotationPartition(ctx context.Context, month time.Time, detach bool) (bool, error) {
+	ctx, cancel := rr.timeouts.withTimeout(ctx, "RemoveQuotationPartition")
+	defer cancel()
+
+	name := partitionName(month)
+
+	tx, err := rr.data.Master().BeginTx(ctx, nil)
+	if err != nil {
+		return false, errs.WithMessage(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var holdsLatest bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM public.`+name+` p
+			WHERE p.time_updated = (
+				SELECT max(l.time_updated)
+				FROM public.quotation l
+				WHERE l.base_currency = p.base_currency AND l.target_currency = p.target_currency))`).
+		Scan(&holdsLatest)
+	if err != nil {
+		return false, errs.WithMessagef(err, "failed to check partition %s", name)
+	}
+	if holdsLatest {
+		return false, nil
+	}
+
+	query := `DROP TABLE public.` + name
+	if detach {
+		query = `ALTER TABLE public.quotation DETACH PARTITION public.` + name
+	}
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return false, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, errs.WithMessagef(err, "failed to remove partition %s", name)
+	}
+
+	return true, nil
+}
@@ -4,81 +4,140 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
 	"github.com/mashmorsik/quotation/infrastructure/data"
 	"github.com/mashmorsik/quotation/pkg/models"
 	errs "github.com/pkg/errors"
+	"strings"
 	"time"
 )
 
+// QuoteRepo stores quotes in Postgres or, with the SQLite migrations, in
+// SQLite. Times are written in UTC so that SQLite, which compares them as
+// text, orders them correctly.
 type QuoteRepo struct {
-	Ctx  context.Context
-	data *data.Data
+	data     *data.Data
+	driver   string
+	timeouts Timeouts
+	// tx is set on the copy handed out by WithTx.
+	tx *sql.Tx
 }
 
-func NewQuoteRepo(ctx context.Context, data *data.Data) *QuoteRepo {
-	return &QuoteRepo{Ctx: ctx, data: data}
+func NewQuoteRepo(data *data.Data, conf *config.Config) *QuoteRepo {
+	return &QuoteRepo{data: data, driver: DriverPostgres, timeouts: NewTimeouts(conf)}
 }
 
-func (qr *QuoteRepo) AddQuotePair(from, to string) error {
-	ctx, cancel := context.WithTimeout(qr.Ctx, time.Second*5)
-	defer cancel()
+func NewSQLiteRepo(data *data.Data, conf *config.Config) *QuoteRepo {
+	return &QuoteRepo{data: data, driver: DriverSQLite, timeouts: NewTimeouts(conf)}
+}
 
-	qPair, err := qr.GetQuotePair(from, to)
-	if err != nil {
-		return err
+// WithTx runs fn with a QuoteRepo whose statements, reads included, all go
+// through one transaction on the primary. It commits when fn returns nil.
+// Calling WithTx on that QuoteRepo joins the running transaction.
+func (qr *QuoteRepo) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	if qr.tx != nil {
+		return fn(qr)
 	}
 
-	if qPair != nil {
+	return qr.data.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		txRepo := *qr
+		txRepo.tx = tx
+		return fn(&txRepo)
+	})
+}
+
+// LockQuotePair serialises transactions on a pair until the calling one
+// ends; it needs a QuoteRepo from WithTx. SQLite runs every transaction on
+// its single connection, so there is nothing to lock.
+func (qr *QuoteRepo) LockQuotePair(ctx context.Context, from, to string) error {
+	if qr.tx == nil {
+		return errs.New("LockQuotePair needs a transaction, see WithTx")
+	}
+	if qr.driver == DriverSQLite {
 		return nil
 	}
 
-	res, err := qr.data.Master().ExecContext(ctx, `
-		INSERT INTO quote_pair (base_currency, target_currency)
-		VALUES ($1, $2)`, from, to)
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "LockQuotePair")
+	defer cancel()
+
+	_, err := qr.tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "quote_pair:"+from+"/"+to)
 	if err != nil {
-		return errs.WithMessagef(err, "failed to add quote pair to database, "+
-			"from: %s, to: %s\n", from, to)
+		return errs.WithMessagef(err, "failed to lock quote pair %s/%s", from, to)
 	}
-	ra, _ := res.RowsAffected()
-	logger.Infof("rows affected: %v", ra)
 
 	return nil
 }
 
-func (qr *QuoteRepo) GetQuotePair(from, to string) (*int32, error) {
-	ctx, cancel := context.WithTimeout(qr.Ctx, time.Second*5)
+// writer returns where statements that change data run.
+func (qr *QuoteRepo) writer() data.Querier {
+	if qr.tx != nil {
+		return qr.tx
+	}
+	return qr.data.Master()
+}
+
+// reader returns where reads made with ctx run, see data.Data.Reader.
+func (qr *QuoteRepo) reader(ctx context.Context) data.Querier {
+	if qr.tx != nil {
+		return qr.tx
+	}
+	return qr.data.Reader(ctx)
+}
+
+// AddQuotePair starts tracking a pair and reports whether it was new;
+// tracking it again is a no-op. A pair removed with DeleteQuotePair is not
+// tracked again, ErrQuotePairRemoved is returned instead.
+func (qr *QuoteRepo) AddQuotePair(ctx context.Context, from, to string) (bool, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "AddQuotePair")
 	defer cancel()
 
-	var quotePairId int32
+	res, err := qr.writer().ExecContext(ctx, `
+		INSERT INTO quote_pair (base_currency, target_currency)
+		VALUES ($1, $2)
+		ON CONFLICT (base_currency, target_currency) DO NOTHING`, from, to)
+	if err != nil {
+		return false, errs.WithMessagef(err, "failed to add quote pair to database, "+
+			"from: %s, to: %s\n", from, to)
+	}
+	ra, _ := res.RowsAffected()
+	logger.Infof("rows affected: %v", ra)
+	if ra > 0 {
+		return true, nil
+	}
 
-	err := qr.data.Master().QueryRowContext(ctx,
-		`SELECT id 
+	var removed bool
+	err = qr.writer().QueryRowContext(ctx, `
+		SELECT removed_at IS NOT NULL
 		FROM quote_pair
-		WHERE base_currency = $1 AND target_currency = $2`, from, to).Scan(&quotePairId)
+		WHERE base_currency = $1 AND target_currency = $2`, from, to).Scan(&removed)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-
-		return nil, errs.WithMessagef(err, "failed to exec query: GetQuotePair")
+		return false, errs.WithMessagef(err, "failed to get quote pair %s/%s", from, to)
+	}
+	if removed {
+		return false, errs.WithMessagef(ErrQuotePairRemoved, "%s/%s", from, to)
 	}
 
-	return &quotePairId, nil
+	return false, nil
 }
 
-func (qr *QuoteRepo) GetQuotePairs() ([][]string, error) {
-	ctx, cancel := context.WithTimeout(qr.Ctx, time.Second*5)
+// GetQuotePairs returns the enabled pairs, i.e. the ones the scheduler polls.
+// Like every read it goes to a replica unless ctx asks for the primary, see
+// data.WithPrimary.
+func (qr *QuoteRepo) GetQuotePairs(ctx context.Context) ([][]string, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetQuotePairs")
 	defer cancel()
 
 	var quotePairs [][]string
 
 	query := `
 		SELECT base_currency, target_currency
-		FROM quote_pair`
+		FROM quote_pair
+		WHERE enabled`
 
-	rows, err := qr.data.Master().QueryContext(ctx, query)
+	rows, err := qr.reader(ctx).QueryContext(ctx, query)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errs.WithMessage(err, "no quote pairs found")
@@ -109,15 +168,17 @@ func (qr *QuoteRepo) GetQuotePairs() ([][]string, error) {
 	return quotePairs, nil
 }
 
-func (qr *QuoteRepo) AddQuotation(q *models.Quote) error {
-	ctx, cancel := context.WithTimeout(qr.Ctx, time.Second*5)
+// AddQuotation stores a quote. Its pair must be tracked, see AddQuotePair.
+func (qr *QuoteRepo) AddQuotation(ctx context.Context, q *models.Quote) error {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "AddQuotation")
 	defer cancel()
 
 	query := `
 		INSERT INTO quotation (id, base_currency, target_currency, rate, time_updated) 
 		VALUES ($1, $2, $3, $4, $5)`
 
-	_, err := qr.data.Master().ExecContext(ctx, query, q.ID, q.BaseCurrency, q.TargetCurrency, q.Rate, q.Timestamp)
+	_, err := qr.writer().ExecContext(ctx, query, q.ID, q.BaseCurrency, q.TargetCurrency, q.Rate,
+		q.Timestamp.UTC())
 	if err != nil {
 		return errs.WithMessagef(err, "failed to add quote for quoteID: %s", q.ID)
 	}
@@ -125,8 +186,120 @@ func (qr *QuoteRepo) AddQuotation(q *models.Quote) error {
 	return nil
 }
 
-func (qr *QuoteRepo) GetQuotation(id uuid.UUID) (*models.Quote, error) {
-	ctx, cancel := context.WithTimeout(qr.Ctx, time.Second*5)
+// quotationBatchRows caps the rows of one multi-row INSERT, keeping it well
+// under the bind parameter limits of Postgres and SQLite.
+const quotationBatchRows = 1000
+
+// AddQuotations stores quotes in one transaction using multi-row inserts, so
+// either all of them are stored or none. When the batch fails every quote is
+// tried on its own in a transaction that is rolled back, and the ones that
+// fail are returned in a *BatchError. Inside WithTx the batch joins the
+// running transaction and is not diagnosed.
+func (qr *QuoteRepo) AddQuotations(ctx context.Context, quotes []*models.Quote) error {
+	if len(quotes) == 0 {
+		return nil
+	}
+
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "AddQuotations")
+	defer cancel()
+
+	err := qr.insertQuotations(ctx, quotes)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil || qr.tx != nil {
+		// a failed statement aborts the caller's transaction, so there is
+		// nothing left to diagnose in it
+		return errs.WithMessagef(err, "failed to add %d quotes", len(quotes))
+	}
+
+	failed, diagErr := qr.failingQuotations(ctx, quotes)
+	if diagErr != nil {
+		logger.Errf("failed to find the failing quotes of the batch: %v", diagErr)
+	}
+	if len(failed) == 0 {
+		return errs.WithMessagef(err, "failed to add %d quotes", len(quotes))
+	}
+
+	return &BatchError{Failed: failed}
+}
+
+func (qr *QuoteRepo) insertQuotations(ctx context.Context, quotes []*models.Quote) error {
+	if qr.tx != nil {
+		return insertQuotationRows(ctx, qr.tx, quotes)
+	}
+
+	return qr.data.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		return insertQuotationRows(ctx, tx, quotes)
+	})
+}
+
+func insertQuotationRows(ctx context.Context, tx *sql.Tx, quotes []*models.Quote) error {
+	for start := 0; start < len(quotes); start += quotationBatchRows {
+		end := min(start+quotationBatchRows, len(quotes))
+
+		var (
+			query strings.Builder
+			args  = make([]any, 0, (end-start)*5)
+		)
+		query.WriteString(`
+		INSERT INTO quotation (id, base_currency, target_currency, rate, time_updated)
+		VALUES `)
+		for i, q := range quotes[start:end] {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5)
+			args = append(args, q.ID, q.BaseCurrency, q.TargetCurrency, q.Rate, q.Timestamp.UTC())
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return errs.WithMessagef(err, "failed to insert quotes %d to %d of the batch", start, end)
+		}
+	}
+
+	return nil
+}
+
+// failingQuotations inserts quotes one by one, each under its own savepoint,
+// and returns the ones that fail. The transaction is always rolled back.
+func (qr *QuoteRepo) failingQuotations(ctx context.Context, quotes []*models.Quote) ([]FailedQuote, error) {
+	tx, err := qr.data.Master().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errs.WithMessage(err, "failed to begin transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var failed []FailedQuote
+	for _, q := range quotes {
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT quote`); err != nil {
+			return failed, errs.WithMessage(err, "failed to create savepoint")
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO quotation (id, base_currency, target_currency, rate, time_updated)
+			VALUES ($1, $2, $3, $4, $5)`, q.ID, q.BaseCurrency, q.TargetCurrency, q.Rate, q.Timestamp.UTC())
+		if err != nil {
+			failed = append(failed, FailedQuote{Quote: q, Err: err})
+			if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT quote`); err != nil {
+				return failed, errs.WithMessage(err, "failed to roll back to savepoint")
+			}
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT quote`); err != nil {
+			return failed, errs.WithMessage(err, "failed to release savepoint")
+		}
+	}
+
+	return failed, nil
+}
+
+func (qr *QuoteRepo) GetQuotation(ctx context.Context, id uuid.UUID) (*models.Quote, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetQuotation")
 	defer cancel()
 
 	var q models.Quote
@@ -136,7 +309,13 @@ func (qr *QuoteRepo) GetQuotation(id uuid.UUID) (*models.Quote, error) {
 		FROM quotation
 		WHERE id = $1`
 
-	err := qr.data.Master().QueryRowContext(ctx, query, id).Scan(&q.ID, &q.BaseCurrency, &q.TargetCurrency, &q.Rate, &q.Timestamp)
+	reader := qr.reader(ctx)
+	err := reader.QueryRowContext(ctx, query, id).Scan(&q.ID, &q.BaseCurrency, &q.TargetCurrency, &q.Rate, &q.Timestamp)
+	if errors.Is(err, sql.ErrNoRows) && reader != data.Querier(qr.data.Master()) {
+		// the quote may be too fresh to have reached the replica
+		err = qr.data.Master().QueryRowContext(ctx, query, id).
+			Scan(&q.ID, &q.BaseCurrency, &q.TargetCurrency, &q.Rate, &q.Timestamp)
+	}
 	if err != nil {
 		return nil, errs.WithMessagef(err, "failed to get quote for id: %s", id)
 	}
@@ -144,13 +323,17 @@ func (qr *QuoteRepo) GetQuotation(id uuid.UUID) (*models.Quote, error) {
 	return &q, nil
 }
 
-func (qr *QuoteRepo) GetLastUpdated(from, to string) (*models.Quote, error) {
-	ctx, cancel := context.WithTimeout(qr.Ctx, time.Second*5)
+// GetLastUpdated returns the newest quote of a pair, or nil if it has none.
+// Unlike GetQuotation it has no fallback to the primary: a replica lagging
+// behind returns an older quote, not an error. Callers reading their own
+// insert back pass data.WithPrimary(ctx), or read inside WithTx.
+func (qr *QuoteRepo) GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetLastUpdated")
 	defer cancel()
 
 	var q models.Quote
 
-	err := qr.data.Master().QueryRowContext(ctx, `
+	err := qr.reader(ctx).QueryRowContext(ctx, `
 		SELECT * 
 		FROM quotation
 		WHERE base_currency = $1 AND target_currency = $2
@@ -167,3 +350,278 @@ func (qr *QuoteRepo) GetLastUpdated(from, to string) (*models.Quote, error) {
 
 	return &q, nil
 }
+
+func (qr *QuoteRepo) GetQuotationsSince(ctx context.Context, from, to string, since time.Time) ([]*models.Quote, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetQuotationsSince")
+	defer cancel()
+
+	query := `
+		SELECT id, base_currency, target_currency, rate, time_updated
+		FROM quotation
+		WHERE base_currency = $1 AND target_currency = $2 AND time_updated >= $3
+		ORDER BY time_updated`
+
+	rows, err := qr.reader(ctx).QueryContext(ctx, query, from, to, since.UTC())
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to get quotes for %s/%s since %s", from, to, since)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var quotes []*models.Quote
+	for rows.Next() {
+		var q models.Quote
+		if err = rows.Scan(&q.ID, &q.BaseCurrency, &q.TargetCurrency, &q.Rate, &q.Timestamp); err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		quotes = append(quotes, &q)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return quotes, nil
+}
+
+// GetLatestQuotes returns the freshest stored quote of every enabled pair,
+// of those with base as base currency unless base is empty. Every pair is
+// one index lookup on (base_currency, target_currency, time_updated).
+func (qr *QuoteRepo) GetLatestQuotes(ctx context.Context, base string) ([]*models.Quote, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetLatestQuotes")
+	defer cancel()
+
+	query := `
+		SELECT q.id, p.base_currency, p.target_currency, q.rate, q.time_updated
+		FROM quote_pair p
+		CROSS JOIN LATERAL (
+			SELECT id, rate, time_updated
+			FROM quotation
+			WHERE base_currency = p.base_currency AND target_currency = p.target_currency
+			ORDER BY time_updated DESC
+			LIMIT 1) q
+		WHERE p.enabled AND ($1 = '' OR p.base_currency = $1)
+		ORDER BY p.base_currency, p.target_currency`
+	if qr.driver == DriverSQLite {
+		query = `
+		SELECT q.id, q.base_currency, q.target_currency, q.rate, q.time_updated
+		FROM quote_pair p
+		JOIN quotation q ON q.id = (
+			SELECT id
+			FROM quotation
+			WHERE base_currency = p.base_currency AND target_currency = p.target_currency
+			ORDER BY time_updated DESC
+			LIMIT 1)
+		WHERE p.enabled AND ($1 = '' OR p.base_currency = $1)
+		ORDER BY p.base_currency, p.target_currency`
+	}
+
+	rows, err := qr.reader(ctx).QueryContext(ctx, query, base)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var quotes []*models.Quote
+	for rows.Next() {
+		var q models.Quote
+		if err = rows.Scan(&q.ID, &q.BaseCurrency, &q.TargetCurrency, &q.Rate, &q.Timestamp); err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		quotes = append(quotes, &q)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return quotes, nil
+}
+
+const trackedPairColumns = `id, base_currency, target_currency, enabled, schedule, created_at, last_fetched_at,
+		last_error`
+
+func scanTrackedPair(row rowScanner) (*models.TrackedPair, error) {
+	var p models.TrackedPair
+	var lastFetched sql.NullTime
+
+	err := row.Scan(&p.ID, &p.BaseCurrency, &p.TargetCurrency, &p.Enabled, &p.Schedule, &p.CreatedAt, &lastFetched,
+		&p.LastError)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastFetched.Valid {
+		p.LastFetchedAt = &lastFetched.Time
+	}
+
+	return &p, nil
+}
+
+func (qr *QuoteRepo) GetTrackedPairs(ctx context.Context) ([]*models.TrackedPair, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetTrackedPairs")
+	defer cancel()
+
+	query := `
+		SELECT ` + trackedPairColumns + `
+		FROM quote_pair
+		WHERE removed_at IS NULL
+		ORDER BY base_currency, target_currency`
+
+	rows, err := qr.reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var pairs []*models.TrackedPair
+	for rows.Next() {
+		p, err := scanTrackedPair(rows)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		pairs = append(pairs, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return pairs, nil
+}
+
+func (qr *QuoteRepo) GetTrackedPair(ctx context.Context, from, to string) (*models.TrackedPair, error) {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "GetTrackedPair")
+	defer cancel()
+
+	p, err := scanTrackedPair(qr.reader(ctx).QueryRowContext(ctx, `
+		SELECT `+trackedPairColumns+`
+		FROM quote_pair
+		WHERE base_currency = $1 AND target_currency = $2 AND removed_at IS NULL`, from, to))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errs.WithMessagef(err, "failed to get quote pair %s/%s", from, to)
+	}
+
+	return p, nil
+}
+
+// SetQuotePairEnabled pauses or resumes polling of a pair. It returns
+// sql.ErrNoRows if the pair is not tracked.
+func (qr *QuoteRepo) SetQuotePairEnabled(ctx context.Context, from, to string, enabled bool) error {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "SetQuotePairEnabled")
+	defer cancel()
+
+	res, err := qr.writer().ExecContext(ctx, `
+		UPDATE quote_pair
+		SET enabled = $3
+		WHERE base_currency = $1 AND target_currency = $2 AND removed_at IS NULL`, from, to, enabled)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to update quote pair %s/%s", from, to)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "quote pair not found: %s/%s", from, to)
+	}
+
+	return nil
+}
+
+// SetQuotePairSchedule sets the refresh schedule of a pair; an empty schedule
+// falls back to the global one. It returns sql.ErrNoRows if the pair is not
+// tracked.
+func (qr *QuoteRepo) SetQuotePairSchedule(ctx context.Context, from, to, schedule string) error {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "SetQuotePairSchedule")
+	defer cancel()
+
+	res, err := qr.writer().ExecContext(ctx, `
+		UPDATE quote_pair
+		SET schedule = $3
+		WHERE base_currency = $1 AND target_currency = $2 AND removed_at IS NULL`, from, to, schedule)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to update quote pair %s/%s", from, to)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "quote pair not found: %s/%s", from, to)
+	}
+
+	return nil
+}
+
+// DeleteQuotePair stops tracking a pair. Stored quotations are kept, and so
+// is the row, as a tombstone AddQuotePair respects. It returns sql.ErrNoRows
+// if the pair is not tracked.
+func (qr *QuoteRepo) DeleteQuotePair(ctx context.Context, from, to string) error {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "DeleteQuotePair")
+	defer cancel()
+
+	res, err := qr.writer().ExecContext(ctx, `
+		UPDATE quote_pair
+		SET removed_at = $3, enabled = false
+		WHERE base_currency = $1 AND target_currency = $2 AND removed_at IS NULL`, from, to, time.Now().UTC())
+	if err != nil {
+		return errs.WithMessagef(err, "failed to delete quote pair %s/%s", from, to)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "quote pair not found: %s/%s", from, to)
+	}
+
+	return nil
+}
+
+// RestoreQuotePair tracks a removed pair again, enabled and with fresh
+// metadata. It returns sql.ErrNoRows if the pair was not removed.
+func (qr *QuoteRepo) RestoreQuotePair(ctx context.Context, from, to string) error {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "RestoreQuotePair")
+	defer cancel()
+
+	res, err := qr.writer().ExecContext(ctx, `
+		UPDATE quote_pair
+		SET removed_at = NULL, enabled = true, schedule = '', created_at = $3, last_fetched_at = NULL,
+			last_error = ''
+		WHERE base_currency = $1 AND target_currency = $2 AND removed_at IS NOT NULL`, from, to, time.Now().UTC())
+	if err != nil {
+		return errs.WithMessagef(err, "failed to restore quote pair %s/%s", from, to)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "removed quote pair not found: %s/%s", from, to)
+	}
+
+	return nil
+}
+
+// RecordQuotePairFetch stores the outcome of a scheduled fetch. An empty
+// fetchErr clears the last error.
+func (qr *QuoteRepo) RecordQuotePairFetch(ctx context.Context, from, to string, at time.Time, fetchErr string) error {
+	ctx, cancel := qr.timeouts.withTimeout(ctx, "RecordQuotePairFetch")
+	defer cancel()
+
+	_, err := qr.writer().ExecContext(ctx, `
+		UPDATE quote_pair
+		SET last_fetched_at = $3, last_error = $4
+		WHERE base_currency = $1 AND target_currency = $2`, from, to, at.UTC(), fetchErr)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to record fetch for quote pair %s/%s", from, to)
+	}
+
+	return nil
+}
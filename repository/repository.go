@@ -1,14 +1,122 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/mashmorsik/quotation/pkg/models"
+	"strings"
+	"time"
 )
 
+// Storage drivers selectable with storage.driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// ErrQuotePairRemoved is returned by AddQuotePair for a pair removed with
+// DeleteQuotePair. Only RestoreQuotePair tracks it again.
+var ErrQuotePairRemoved = errors.New("quote pair was removed")
+
+// FailedQuote is a quote of a batch that could not be stored.
+type FailedQuote struct {
+	Quote *models.Quote
+	Err   error
+}
+
+// BatchError is returned by AddQuotations when some quotes of the batch
+// cannot be stored. The batch is all or nothing, so none of it was stored.
+type BatchError struct {
+	Failed []FailedQuote
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("%s %s/%s: %v", f.Quote.ID, f.Quote.BaseCurrency, f.Quote.TargetCurrency, f.Err))
+	}
+	return fmt.Sprintf("%d quotes of the batch failed: %s", len(e.Failed), strings.Join(msgs, "; "))
+}
+
 type Repository interface {
-	AddQuotePair(from, to string) error
-	GetQuotePairs() ([][]string, error)
-	AddQuotation(q *models.Quote) error
-	GetQuotation(id uuid.UUID) (*models.Quote, error)
-	GetLastUpdated(from, to string) (*models.Quote, error)
+	AddQuotePair(ctx context.Context, from, to string) (bool, error)
+	GetQuotePairs(ctx context.Context) ([][]string, error)
+	AddQuotation(ctx context.Context, q *models.Quote) error
+	AddQuotations(ctx context.Context, quotes []*models.Quote) error
+	GetQuotation(ctx context.Context, id uuid.UUID) (*models.Quote, error)
+	GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error)
+	GetQuotationsSince(ctx context.Context, from, to string, since time.Time) ([]*models.Quote, error)
+	GetLatestQuotes(ctx context.Context, base string) ([]*models.Quote, error)
+	GetTrackedPairs(ctx context.Context) ([]*models.TrackedPair, error)
+	GetTrackedPair(ctx context.Context, from, to string) (*models.TrackedPair, error)
+	SetQuotePairEnabled(ctx context.Context, from, to string, enabled bool) error
+	SetQuotePairSchedule(ctx context.Context, from, to, schedule string) error
+	DeleteQuotePair(ctx context.Context, from, to string) error
+	RestoreQuotePair(ctx context.Context, from, to string) error
+	RecordQuotePairFetch(ctx context.Context, from, to string, at time.Time, fetchErr string) error
+	// WithTx runs fn with a Repository bound to one transaction, committed
+	// when fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(repo Repository) error) error
+	// LockQuotePair holds off other transactions locking the same pair until
+	// the current one ends. It is only valid inside WithTx.
+	LockQuotePair(ctx context.Context, from, to string) error
+}
+
+type AlertRepository interface {
+	AddAlertRule(ctx context.Context, r *models.AlertRule) error
+	GetAlertRule(ctx context.Context, id uuid.UUID) (*models.AlertRule, error)
+	GetAlertRules(ctx context.Context) ([]*models.AlertRule, error)
+	GetAlertRulesForPair(ctx context.Context, from, to string) ([]*models.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, r *models.AlertRule) error
+	DeleteAlertRule(ctx context.Context, id uuid.UUID) error
+	SetAlertRuleMatching(ctx context.Context, id uuid.UUID, matching bool) (bool, error)
+	AddAlertFiring(ctx context.Context, f *models.AlertFiring) (bool, error)
+	GetAlertFirings(ctx context.Context, ruleID uuid.UUID) ([]*models.AlertFiring, error)
+}
+
+type WebhookRepository interface {
+	AddWebhookEndpoint(ctx context.Context, e *models.WebhookEndpoint) error
+	GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error)
+	GetWebhookEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	GetWebhookEndpointsForEvent(ctx context.Context, event string) ([]*models.WebhookEndpoint, error)
+	SetWebhookEndpointEnabled(ctx context.Context, id uuid.UUID, enabled bool) error
+	DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+	RecordWebhookEndpointResult(ctx context.Context, id uuid.UUID, success bool, disableAfter int) (bool, error)
+	AddWebhookMessage(ctx context.Context, m *models.WebhookMessage) error
+	ClaimDueWebhookMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookMessage, error)
+	UpdateWebhookMessage(ctx context.Context, m *models.WebhookMessage) error
+	AddWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error
+	GetWebhookDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*models.WebhookDelivery, error)
+	DeleteWebhookMessagesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	DeleteWebhookDeliveriesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+type RetentionRepository interface {
+	HourlyWatermark(ctx context.Context) (time.Time, error)
+	DailyWatermark(ctx context.Context) (time.Time, error)
+	RollupHourly(ctx context.Context, from, to time.Time) (int64, error)
+	RollupDaily(ctx context.Context, from, to time.Time) (int64, error)
+	DeleteRawBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	DeleteHourlyBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	DeleteDailyBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+// PartitionRepository manages the monthly partitions of quotation. Months
+// are identified by any instant within them.
+type PartitionRepository interface {
+	QuotationPartitions(ctx context.Context) ([]time.Time, error)
+	CreateQuotationPartition(ctx context.Context, month time.Time) error
+	RemoveQuotationPartition(ctx context.Context, month time.Time, detach bool) (bool, error)
+}
+
+type APIKeyRepository interface {
+	AddAPIKey(ctx context.Context, k *models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	IncrementAPIKeyUsage(ctx context.Context, id uuid.UUID, day time.Time, quota int) (int64, bool, error)
+	GetAPIKeyUsage(ctx context.Context, id uuid.UUID, since time.Time) ([]*models.APIKeyUsage, error)
 }
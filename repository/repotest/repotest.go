@@ -0,0 +1,431 @@
+// Package repotest is a conformance suite for repository.Repository
+// implementations.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mashmorsik/quotation/pkg/models"
+	"github.com/mashmorsik/quotation/repository"
+	"github.com/shopspring/decimal"
+	"sync"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+// Run checks the behaviour every Repository must share. newRepo is called
+// once per subtest and must return an empty repository.
+func Run(t *testing.T, newRepo func(t *testing.T) repository.Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.Repository)
+	}{
+		{"add_get_quotation", testAddGetQuotation},
+		{"add_quotations", testAddQuotations},
+		{"quotations_since", testQuotationsSince},
+		{"latest", testLatest},
+		{"not_found", testNotFound},
+		{"pair_dedupe", testPairDedupe},
+		{"pair_lifecycle", testPairLifecycle},
+		{"concurrent", testConcurrent},
+		{"tx_create_or_reuse", testTxCreateOrReuse},
+		{"cancelled", testCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func newQuote(from, to string, at time.Time, rate string) *models.Quote {
+	return &models.Quote{
+		ID:             uuid.New(),
+		BaseCurrency:   from,
+		TargetCurrency: to,
+		Timestamp:      at,
+		Rate:           decimal.RequireFromString(rate),
+	}
+}
+
+// mustAddQuotation tracks the pair of q, as quotes of untracked pairs are
+// rejected, and stores q.
+func mustAddQuotation(t *testing.T, repo repository.Repository, q *models.Quote) {
+	t.Helper()
+	if _, err := repo.AddQuotePair(context.Background(), q.BaseCurrency, q.TargetCurrency); err != nil {
+		t.Fatalf("AddQuotePair() error = %v", err)
+	}
+	if err := repo.AddQuotation(context.Background(), q); err != nil {
+		t.Fatalf("AddQuotation() error = %v", err)
+	}
+}
+
+func testAddGetQuotation(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	q := newQuote("EUR", "USD", base.In(time.FixedZone("MSK", 3*3600)), "1.0842317")
+	mustAddQuotation(t, repo, q)
+
+	got, err := repo.GetQuotation(ctx, q.ID)
+	if err != nil {
+		t.Fatalf("GetQuotation() error = %v", err)
+	}
+	if got.ID != q.ID || got.BaseCurrency != "EUR" || got.TargetCurrency != "USD" {
+		t.Errorf("GetQuotation() = %+v, want %+v", got, q)
+	}
+	if !got.Rate.Equal(q.Rate) {
+		t.Errorf("GetQuotation() rate = %s, want %s", got.Rate, q.Rate)
+	}
+	if !got.Timestamp.Equal(q.Timestamp) {
+		t.Errorf("GetQuotation() timestamp = %s, want %s", got.Timestamp, q.Timestamp)
+	}
+
+	if err = repo.AddQuotation(ctx, newQuoteWithID(q.ID)); err == nil {
+		t.Error("AddQuotation() with a duplicate id expected error")
+	}
+	if err = repo.AddQuotation(ctx, newQuote("EUR", "MXN", base, "20")); err == nil {
+		t.Error("AddQuotation() for an untracked pair expected error")
+	}
+}
+
+func newQuoteWithID(id uuid.UUID) *models.Quote {
+	q := newQuote("EUR", "USD", base, "1")
+	q.ID = id
+	return q
+}
+
+func testAddQuotations(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	if _, err := repo.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatalf("AddQuotePair() error = %v", err)
+	}
+
+	stored := newQuote("EUR", "USD", base, "1")
+	mustAddQuotation(t, repo, stored)
+
+	duplicate := newQuoteWithID(stored.ID)
+	untracked := newQuote("EUR", "MXN", base, "20")
+	valid := newQuote("EUR", "USD", base.Add(time.Minute), "1.1")
+	err := repo.AddQuotations(ctx, []*models.Quote{valid, duplicate, untracked})
+
+	var batchErr *repository.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("AddQuotations() error = %v, want *BatchError", err)
+	}
+	if len(batchErr.Failed) != 2 || batchErr.Failed[0].Quote != duplicate || batchErr.Failed[1].Quote != untracked {
+		t.Errorf("AddQuotations() failed = %v, want the duplicate and the untracked quote", batchErr.Failed)
+	}
+	if _, err = repo.GetQuotation(ctx, valid.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetQuotation() after failed batch error = %v, want sql.ErrNoRows", err)
+	}
+
+	batch := make([]*models.Quote, 0, 1500)
+	for i := 0; i < cap(batch); i++ {
+		batch = append(batch, newQuote("EUR", "USD", base.Add(time.Duration(i+1)*time.Second), "1"))
+	}
+	if err = repo.AddQuotations(ctx, batch); err != nil {
+		t.Fatalf("AddQuotations() error = %v", err)
+	}
+	quotes, err := repo.GetQuotationsSince(ctx, "EUR", "USD", base)
+	if err != nil || len(quotes) != len(batch)+1 {
+		t.Errorf("GetQuotationsSince() = %d quotes, %v, want %d", len(quotes), err, len(batch)+1)
+	}
+}
+
+func testQuotationsSince(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	// inserted out of order on purpose
+	byOffset := make(map[int]uuid.UUID)
+	for _, offset := range []int{2, 0, 3, 1} {
+		q := newQuote("EUR", "USD", base.Add(time.Duration(offset)*time.Minute), fmt.Sprint(offset))
+		mustAddQuotation(t, repo, q)
+		byOffset[offset] = q.ID
+	}
+	mustAddQuotation(t, repo, newQuote("USD", "EUR", base.Add(2*time.Minute), "1"))
+
+	quotes, err := repo.GetQuotationsSince(ctx, "EUR", "USD", base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetQuotationsSince() error = %v", err)
+	}
+	if len(quotes) != 3 {
+		t.Fatalf("GetQuotationsSince() returned %d quotes, want 3", len(quotes))
+	}
+	for i, offset := range []int{1, 2, 3} {
+		if quotes[i].ID != byOffset[offset] {
+			t.Errorf("GetQuotationsSince()[%d] = %s, want the quote at +%dm", i, quotes[i].Timestamp, offset)
+		}
+	}
+
+	last, err := repo.GetLastUpdated(ctx, "EUR", "USD")
+	if err != nil || last == nil || last.ID != byOffset[3] {
+		t.Errorf("GetLastUpdated() = %v, %v, want the quote at +3m", last, err)
+	}
+}
+
+func testLatest(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	mustAddQuotation(t, repo, newQuote("USD", "EUR", base, "0.9"))
+	latestUSD := newQuote("USD", "EUR", base.Add(time.Hour), "0.91")
+	mustAddQuotation(t, repo, latestUSD)
+	// the same instant in another zone must compare as that instant
+	latestEUR := newQuote("EUR", "MXN", base.Add(2*time.Hour).In(time.FixedZone("MSK", 3*3600)), "20")
+	mustAddQuotation(t, repo, latestEUR)
+	mustAddQuotation(t, repo, newQuote("EUR", "MXN", base.Add(time.Hour), "19"))
+
+	quotes, err := repo.GetLatestQuotes(ctx, "")
+	if err != nil {
+		t.Fatalf("GetLatestQuotes() error = %v", err)
+	}
+	if len(quotes) != 2 || quotes[0].ID != latestEUR.ID || quotes[1].ID != latestUSD.ID {
+		t.Fatalf("GetLatestQuotes() = %v, want EUR/MXN then USD/EUR", quotes)
+	}
+	if !quotes[0].Rate.Equal(latestEUR.Rate) {
+		t.Errorf("GetLatestQuotes() EUR/MXN rate = %s, want %s", quotes[0].Rate, latestEUR.Rate)
+	}
+
+	quotes, err = repo.GetLatestQuotes(ctx, "USD")
+	if err != nil || len(quotes) != 1 || quotes[0].ID != latestUSD.ID {
+		t.Errorf("GetLatestQuotes(USD) = %v, %v, want USD/EUR only", quotes, err)
+	}
+
+	// disabled pairs are left out
+	if err = repo.SetQuotePairEnabled(ctx, "USD", "EUR", false); err != nil {
+		t.Fatalf("SetQuotePairEnabled() error = %v", err)
+	}
+	quotes, err = repo.GetLatestQuotes(ctx, "")
+	if err != nil || len(quotes) != 1 || quotes[0].ID != latestEUR.ID {
+		t.Errorf("GetLatestQuotes() = %v, %v, want EUR/MXN only", quotes, err)
+	}
+}
+
+func testNotFound(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	if _, err := repo.GetQuotation(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetQuotation() error = %v, want sql.ErrNoRows", err)
+	}
+	if q, err := repo.GetLastUpdated(ctx, "EUR", "MXN"); q != nil || err != nil {
+		t.Errorf("GetLastUpdated() = %v, %v, want nil, nil", q, err)
+	}
+	if quotes, err := repo.GetQuotationsSince(ctx, "EUR", "MXN", base); len(quotes) != 0 || err != nil {
+		t.Errorf("GetQuotationsSince() = %v, %v, want no quotes", quotes, err)
+	}
+	if quotes, err := repo.GetLatestQuotes(ctx, ""); len(quotes) != 0 || err != nil {
+		t.Errorf("GetLatestQuotes() = %v, %v, want no quotes", quotes, err)
+	}
+	if p, err := repo.GetTrackedPair(ctx, "EUR", "MXN"); p != nil || err != nil {
+		t.Errorf("GetTrackedPair() = %v, %v, want nil, nil", p, err)
+	}
+	if err := repo.SetQuotePairEnabled(ctx, "EUR", "MXN", false); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SetQuotePairEnabled() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.SetQuotePairSchedule(ctx, "EUR", "MXN", "10m"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SetQuotePairSchedule() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.DeleteQuotePair(ctx, "EUR", "MXN"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteQuotePair() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.RecordQuotePairFetch(ctx, "EUR", "MXN", base, ""); err != nil {
+		t.Errorf("RecordQuotePairFetch() error = %v, want nil", err)
+	}
+}
+
+func testPairDedupe(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	for i, p := range [][]string{{"USD", "EUR"}, {"EUR", "USD"}, {"EUR", "USD"}} {
+		added, err := repo.AddQuotePair(ctx, p[0], p[1])
+		if err != nil {
+			t.Fatalf("AddQuotePair() error = %v", err)
+		}
+		if want := i < 2; added != want {
+			t.Errorf("AddQuotePair(%s/%s) = %v, want %v", p[0], p[1], added, want)
+		}
+	}
+
+	pairs, err := repo.GetTrackedPairs(ctx)
+	if err != nil {
+		t.Fatalf("GetTrackedPairs() error = %v", err)
+	}
+	if len(pairs) != 2 || pairs[0].BaseCurrency != "EUR" || pairs[1].BaseCurrency != "USD" {
+		t.Fatalf("GetTrackedPairs() = %v, want EUR/USD and USD/EUR", pairs)
+	}
+	if !pairs[0].Enabled || pairs[0].Schedule != "" || pairs[0].LastFetchedAt != nil {
+		t.Errorf("new pair = %+v, want enabled with no schedule or fetch", pairs[0])
+	}
+}
+
+func testPairLifecycle(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	for _, p := range [][]string{{"EUR", "USD"}, {"USD", "EUR"}} {
+		if _, err := repo.AddQuotePair(ctx, p[0], p[1]); err != nil {
+			t.Fatalf("AddQuotePair() error = %v", err)
+		}
+	}
+
+	if err := repo.SetQuotePairEnabled(ctx, "USD", "EUR", false); err != nil {
+		t.Fatalf("SetQuotePairEnabled() error = %v", err)
+	}
+	enabled, err := repo.GetQuotePairs(ctx)
+	if err != nil || len(enabled) != 1 || enabled[0][0] != "EUR" || enabled[0][1] != "USD" {
+		t.Errorf("GetQuotePairs() = %v, %v, want only EUR/USD", enabled, err)
+	}
+
+	if err = repo.SetQuotePairSchedule(ctx, "EUR", "USD", "10m"); err != nil {
+		t.Fatalf("SetQuotePairSchedule() error = %v", err)
+	}
+	fetchedAt := base.Add(time.Minute)
+	if err = repo.RecordQuotePairFetch(ctx, "EUR", "USD", fetchedAt, "upstream down"); err != nil {
+		t.Fatalf("RecordQuotePairFetch() error = %v", err)
+	}
+
+	p, err := repo.GetTrackedPair(ctx, "EUR", "USD")
+	if err != nil || p == nil {
+		t.Fatalf("GetTrackedPair() = %v, %v", p, err)
+	}
+	if p.Schedule != "10m" || p.LastError != "upstream down" || p.LastFetchedAt == nil ||
+		!p.LastFetchedAt.Equal(fetchedAt) {
+		t.Errorf("GetTrackedPair() = %+v, want schedule, fetch time and error recorded", p)
+	}
+
+	mustAddQuotation(t, repo, newQuote("EUR", "USD", base, "1"))
+	if err = repo.DeleteQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatalf("DeleteQuotePair() error = %v", err)
+	}
+	if p, _ = repo.GetTrackedPair(ctx, "EUR", "USD"); p != nil {
+		t.Errorf("GetTrackedPair() after delete = %v, want nil", p)
+	}
+	if pairs, err := repo.GetTrackedPairs(ctx); err != nil || len(pairs) != 1 {
+		t.Errorf("GetTrackedPairs() after delete = %v, %v, want only USD/EUR", pairs, err)
+	}
+	// stored quotations are kept
+	if q, err := repo.GetLastUpdated(ctx, "EUR", "USD"); q == nil || err != nil {
+		t.Errorf("GetLastUpdated() after delete = %v, %v, want the stored quote", q, err)
+	}
+	if _, err = repo.AddQuotePair(ctx, "EUR", "USD"); !errors.Is(err, repository.ErrQuotePairRemoved) {
+		t.Errorf("AddQuotePair() after delete error = %v, want ErrQuotePairRemoved", err)
+	}
+	if err = repo.DeleteQuotePair(ctx, "EUR", "USD"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteQuotePair() twice error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err = repo.RestoreQuotePair(ctx, "USD", "EUR"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RestoreQuotePair() of a tracked pair error = %v, want sql.ErrNoRows", err)
+	}
+	if err = repo.RestoreQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Fatalf("RestoreQuotePair() error = %v", err)
+	}
+	p, err = repo.GetTrackedPair(ctx, "EUR", "USD")
+	if err != nil || p == nil || !p.Enabled || p.Schedule != "" || p.LastFetchedAt != nil || p.LastError != "" {
+		t.Errorf("GetTrackedPair() after restore = %+v, %v, want enabled with no schedule or fetch", p, err)
+	}
+	if _, err = repo.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+		t.Errorf("AddQuotePair() after restore error = %v", err)
+	}
+}
+
+func testConcurrent(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	currencies := []string{"EUR", "USD", "MXN", "GBP", "JPY"}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 100)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			from, to := currencies[i%5], currencies[(i+1)%5]
+			// every goroutine tracks its pair so the inserts race
+			if _, err := repo.AddQuotePair(ctx, from, to); err != nil {
+				errCh <- err
+			}
+			q := newQuote(from, to, base.Add(time.Duration(i)*time.Second), "1")
+			if err := repo.AddQuotation(ctx, q); err != nil {
+				errCh <- err
+			}
+			if _, err := repo.GetLatestQuotes(ctx, ""); err != nil {
+				errCh <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Errorf("concurrent call error = %v", err)
+	}
+
+	pairs, err := repo.GetTrackedPairs(ctx)
+	if err != nil || len(pairs) != 5 {
+		t.Errorf("GetTrackedPairs() = %d pairs, %v, want 5", len(pairs), err)
+	}
+	quotes, err := repo.GetQuotationsSince(ctx, "EUR", "USD", time.Time{})
+	if err != nil || len(quotes) != 10 {
+		t.Errorf("GetQuotationsSince() = %d quotes, %v, want 10", len(quotes), err)
+	}
+}
+
+// testTxCreateOrReuse runs the create-or-reuse step of GetQuoteAsync
+// concurrently; the pair lock must let only one of them store a quote.
+func testTxCreateOrReuse(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	if err := repo.LockQuotePair(ctx, "EUR", "USD"); err == nil {
+		t.Error("LockQuotePair() outside WithTx expected error")
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.WithTx(ctx, func(tx repository.Repository) error {
+				if err := tx.LockQuotePair(ctx, "EUR", "USD"); err != nil {
+					return err
+				}
+				if _, err := tx.AddQuotePair(ctx, "EUR", "USD"); err != nil {
+					return err
+				}
+				latest, err := tx.GetLastUpdated(ctx, "EUR", "USD")
+				if err != nil || latest != nil {
+					return err
+				}
+				return tx.AddQuotation(ctx, newQuote("EUR", "USD", time.Now(), "1"))
+			})
+			if err != nil {
+				errCh <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Errorf("WithTx() error = %v", err)
+	}
+
+	quotes, err := repo.GetQuotationsSince(ctx, "EUR", "USD", time.Time{})
+	if err != nil || len(quotes) != 1 {
+		t.Errorf("GetQuotationsSince() = %d quotes, %v, want 1", len(quotes), err)
+	}
+}
+
+func testCancelled(t *testing.T, repo repository.Repository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.GetLatestQuotes(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetLatestQuotes() error = %v, want context.Canceled", err)
+	}
+	if _, err := repo.AddQuotePair(ctx, "EUR", "USD"); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddQuotePair() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	errs "github.com/pkg/errors"
+	"time"
+)
+
+type RetentionRepo struct {
+	data     *data.Data
+	timeouts Timeouts
+}
+
+// retentionTimeouts are the defaults of the operations that may touch many
+// rows. storage.timeouts still overrides them.
+var retentionTimeouts = map[string]time.Duration{
+	"RollupHourly":             time.Minute,
+	"RollupDaily":              time.Minute,
+	"DeleteRawBefore":          time.Minute,
+	"DeleteHourlyBefore":       time.Minute,
+	"DeleteDailyBefore":        time.Minute,
+	"CreateQuotationPartition": time.Minute,
+	"RemoveQuotationPartition": time.Minute,
+}
+
+func NewRetentionRepo(data *data.Data, conf *config.Config) *RetentionRepo {
+	return &RetentionRepo{data: data, timeouts: NewTimeouts(conf).withDefaults(retentionTimeouts)}
+}
+
+// HourlyWatermark returns the end of the last rolled up hour. Before the
+// first rollup it is the start of the hour of the oldest raw quotation, and
+// the zero time if there is none either.
+func (rr *RetentionRepo) HourlyWatermark(ctx context.Context) (time.Time, error) {
+	return rr.watermark(ctx, "HourlyWatermark", `
+		SELECT coalesce(
+			(SELECT max(bucket) + interval '1 hour' FROM quotation_hourly),
+			(SELECT date_trunc('hour', min(time_updated) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' FROM quotation))`)
+}
+
+// DailyWatermark returns the end of the last rolled up day. Before the first
+// rollup it is the start of the day of the oldest hourly bucket, and the zero
+// time if there is none either.
+func (rr *RetentionRepo) DailyWatermark(ctx context.Context) (time.Time, error) {
+	return rr.watermark(ctx, "DailyWatermark", `
+		SELECT coalesce(
+			(SELECT max(bucket) + interval '1 day' FROM quotation_daily),
+			(SELECT date_trunc('day', min(bucket) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' FROM quotation_hourly))`)
+}
+
+func (rr *RetentionRepo) watermark(ctx context.Context, op, query string) (time.Time, error) {
+	ctx, cancel := rr.timeouts.withTimeout(ctx, op)
+	defer cancel()
+
+	var t sql.NullTime
+	if err := rr.data.Master().QueryRowContext(ctx, query).Scan(&t); err != nil {
+		return time.Time{}, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+
+	return t.Time, nil
+}
+
+// RollupHourly aggregates raw quotations in [from, to) into hourly OHLC
+// buckets. Buckets that already exist are recomputed, so the call is
+// idempotent.
+func (rr *RetentionRepo) RollupHourly(ctx context.Context, from, to time.Time) (int64, error) {
+	return rr.exec(ctx, "RollupHourly", `
+		INSERT INTO quotation_hourly (base_currency, target_currency, bucket, open, high, low, close, points)
+		SELECT base_currency, target_currency,
+			date_trunc('hour', time_updated AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+			(array_agg(rate ORDER BY time_updated))[1],
+			max(rate),
+			min(rate),
+			(array_agg(rate ORDER BY time_updated DESC))[1],
+			count(*)
+		FROM quotation
+		WHERE time_updated >= $1 AND time_updated < $2
+		GROUP BY 1, 2, 3
+		ON CONFLICT (base_currency, target_currency, bucket) DO UPDATE
+		SET open = excluded.open, high = excluded.high, low = excluded.low,
+			close = excluded.close, points = excluded.points`, from, to)
+}
+
+// RollupDaily aggregates hourly buckets in [from, to) into daily OHLC
+// buckets.
+func (rr *RetentionRepo) RollupDaily(ctx context.Context, from, to time.Time) (int64, error) {
+	return rr.exec(ctx, "RollupDaily", `
+		INSERT INTO quotation_daily (base_currency, target_currency, bucket, open, high, low, close, points)
+		SELECT base_currency, target_currency,
+			date_trunc('day', bucket AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+			(array_agg(open ORDER BY bucket))[1],
+			max(high),
+			min(low),
+			(array_agg(close ORDER BY bucket DESC))[1],
+			sum(points)
+		FROM quotation_hourly
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY 1, 2, 3
+		ON CONFLICT (base_currency, target_currency, bucket) DO UPDATE
+		SET open = excluded.open, high = excluded.high, low = excluded.low,
+			close = excluded.close, points = excluded.points`, from, to)
+}
+
+// DeleteRawBefore deletes up to limit raw quotations older than cutoff. The
+// latest quotation of every pair is always kept so /latest keeps working
+// for pairs that are no longer polled.
+func (rr *RetentionRepo) DeleteRawBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return rr.exec(ctx, "DeleteRawBefore", `
+		DELETE FROM quotation
+		WHERE id IN (
+			SELECT q.id
+			FROM quotation q
+			WHERE q.time_updated < $1
+				AND q.time_updated < (
+					SELECT max(l.time_updated)
+					FROM quotation l
+					WHERE l.base_currency = q.base_currency AND l.target_currency = q.target_currency)
+			LIMIT $2)`, cutoff, limit)
+}
+
+// DeleteHourlyBefore deletes up to limit hourly buckets older than cutoff.
+func (rr *RetentionRepo) DeleteHourlyBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return rr.exec(ctx, "DeleteHourlyBefore", `
+		DELETE FROM quotation_hourly
+		WHERE ctid IN (
+			SELECT ctid
+			FROM quotation_hourly
+			WHERE bucket < $1
+			LIMIT $2)`, cutoff, limit)
+}
+
+// DeleteDailyBefore deletes up to limit daily buckets older than cutoff.
+func (rr *RetentionRepo) DeleteDailyBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return rr.exec(ctx, "DeleteDailyBefore", `
+		DELETE FROM quotation_daily
+		WHERE ctid IN (
+			SELECT ctid
+			FROM quotation_daily
+			WHERE bucket < $1
+			LIMIT $2)`, cutoff, limit)
+}
+
+func (rr *RetentionRepo) exec(ctx context.Context, op, query string, args ...any) (int64, error) {
+	ctx, cancel := rr.timeouts.withTimeout(ctx, op)
+	defer cancel()
+
+	res, err := rr.data.Master().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+
+	ra, _ := res.RowsAffected()
+	return ra, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"github.com/mashmorsik/quotation/config"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Timeouts bounds single repository operations. Operations are named after
+// the Repository methods and matched case-insensitively.
+type Timeouts struct {
+	Default time.Duration
+	PerOp   map[string]time.Duration
+}
+
+func NewTimeouts(conf *config.Config) Timeouts {
+	t := Timeouts{Default: conf.Storage.Timeout, PerOp: make(map[string]time.Duration)}
+	for op, d := range conf.Storage.Timeouts {
+		t.PerOp[strings.ToLower(op)] = d
+	}
+	return t
+}
+
+// withDefaults returns a copy of t in which ops not configured otherwise
+// get the timeouts of defaults.
+func (t Timeouts) withDefaults(defaults map[string]time.Duration) Timeouts {
+	perOp := make(map[string]time.Duration, len(t.PerOp)+len(defaults))
+	for op, d := range defaults {
+		perOp[strings.ToLower(op)] = d
+	}
+	for op, d := range t.PerOp {
+		if d > 0 {
+			perOp[op] = d
+		}
+	}
+	return Timeouts{Default: t.Default, PerOp: perOp}
+}
+
+// For returns the timeout of op.
+func (t Timeouts) For(op string) time.Duration {
+	if d, ok := t.PerOp[strings.ToLower(op)]; ok && d > 0 {
+		return d
+	}
+	if t.Default > 0 {
+		return t.Default
+	}
+	return defaultTimeout
+}
+
+// withTimeout derives the context a single operation runs with. A deadline
+// already on ctx that is sooner wins.
+func (t Timeouts) withTimeout(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.For(op))
+}
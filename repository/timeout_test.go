@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"github.com/mashmorsik/quotation/config"
+	"testing"
+	"time"
+)
+
+func TestTimeouts_For(t *testing.T) {
+	conf := &config.Config{}
+	conf.Storage.Timeout = 2 * time.Second
+	conf.Storage.Timeouts = map[string]time.Duration{"GetAlertRules": time.Second, "RollupHourly": 5 * time.Minute}
+
+	timeouts := NewTimeouts(conf).withDefaults(retentionTimeouts)
+
+	tests := []struct {
+		op   string
+		want time.Duration
+	}{
+		{op: "getalertrules", want: time.Second},
+		{op: "AddAPIKey", want: 2 * time.Second},
+		{op: "RollupHourly", want: 5 * time.Minute},
+		{op: "DeleteRawBefore", want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := timeouts.For(tt.op); got != tt.want {
+			t.Errorf("For(%q) = %v, want %v", tt.op, got, tt.want)
+		}
+	}
+
+	if got := (Timeouts{}).For("AddAPIKey"); got != defaultTimeout {
+		t.Errorf("For() without config = %v, want %v", got, defaultTimeout)
+	}
+}
@@ -0,0 +1,410 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mashmorsik/logger"
+	"github.com/mashmorsik/quotation/config"
+	"github.com/mashmorsik/quotation/infrastructure/data"
+	"github.com/mashmorsik/quotation/pkg/models"
+	errs "github.com/pkg/errors"
+	"time"
+)
+
+const webhookEndpointColumns = `id, url, secret, events, enabled, consecutive_failures, created_at, disabled_at`
+
+const webhookMessageColumns = `id, endpoint_id, url, secret, event, payload, attempts, status,
+		next_attempt_at, last_error, created_at`
+
+type WebhookRepo struct {
+	data     *data.Data
+	timeouts Timeouts
+}
+
+// webhookTimeouts are the defaults of the operations that may touch many
+// rows. storage.timeouts still overrides them.
+var webhookTimeouts = map[string]time.Duration{
+	"DeleteWebhookMessagesBefore":   time.Minute,
+	"DeleteWebhookDeliveriesBefore": time.Minute,
+}
+
+func NewWebhookRepo(data *data.Data, conf *config.Config) *WebhookRepo {
+	return &WebhookRepo{data: data, timeouts: NewTimeouts(conf).withDefaults(webhookTimeouts)}
+}
+
+func scanWebhookEndpoint(row rowScanner) (*models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	var disabledAt sql.NullTime
+
+	err := row.Scan(&e.ID, &e.URL, &e.Secret, pq.Array(&e.Events), &e.Enabled, &e.ConsecutiveFailures,
+		&e.CreatedAt, &disabledAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Events == nil {
+		e.Events = []string{}
+	}
+	if disabledAt.Valid {
+		e.DisabledAt = &disabledAt.Time
+	}
+
+	return &e, nil
+}
+
+func scanWebhookMessage(row rowScanner) (*models.WebhookMessage, error) {
+	var m models.WebhookMessage
+	var endpointID uuid.NullUUID
+
+	err := row.Scan(&m.ID, &endpointID, &m.URL, &m.Secret, &m.Event, &m.Payload, &m.Attempts, &m.Status,
+		&m.NextAttemptAt, &m.LastError, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpointID.Valid {
+		m.EndpointID = &endpointID.UUID
+	}
+
+	return &m, nil
+}
+
+func (wr *WebhookRepo) queryWebhookEndpoints(ctx context.Context, op, query string, args ...any) ([]*models.WebhookEndpoint, error) {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, op)
+	defer cancel()
+
+	rows, err := wr.data.Master().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var endpoints []*models.WebhookEndpoint
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return endpoints, nil
+}
+
+func (wr *WebhookRepo) AddWebhookEndpoint(ctx context.Context, e *models.WebhookEndpoint) error {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "AddWebhookEndpoint")
+	defer cancel()
+
+	_, err := wr.data.Master().ExecContext(ctx, `
+		INSERT INTO webhook_endpoint (id, url, secret, events, enabled, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`, e.ID, e.URL, e.Secret, pq.Array(e.Events), e.Enabled, e.CreatedAt)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to add webhook endpoint: %s", e.ID)
+	}
+
+	return nil
+}
+
+func (wr *WebhookRepo) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "GetWebhookEndpoint")
+	defer cancel()
+
+	e, err := scanWebhookEndpoint(wr.data.Master().QueryRowContext(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoint
+		WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errs.WithMessagef(err, "failed to get webhook endpoint: %s", id)
+	}
+
+	return e, nil
+}
+
+func (wr *WebhookRepo) GetWebhookEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	return wr.queryWebhookEndpoints(ctx, "GetWebhookEndpoints", `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoint
+		ORDER BY created_at`)
+}
+
+// GetWebhookEndpointsForEvent returns enabled endpoints subscribed to event.
+// An endpoint without events is subscribed to all of them.
+func (wr *WebhookRepo) GetWebhookEndpointsForEvent(ctx context.Context, event string) ([]*models.WebhookEndpoint, error) {
+	return wr.queryWebhookEndpoints(ctx, "GetWebhookEndpointsForEvent", `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoint
+		WHERE enabled AND (cardinality(events) = 0 OR $1 = ANY(events))`, event)
+}
+
+// SetWebhookEndpointEnabled enables or disables an endpoint. Enabling resets
+// its failure counter. It returns sql.ErrNoRows if the endpoint does not exist.
+func (wr *WebhookRepo) SetWebhookEndpointEnabled(ctx context.Context, id uuid.UUID, enabled bool) error {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "SetWebhookEndpointEnabled")
+	defer cancel()
+
+	res, err := wr.data.Master().ExecContext(ctx, `
+		UPDATE webhook_endpoint
+		SET enabled = $2,
+			consecutive_failures = CASE WHEN $2 THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN $2 THEN NULL ELSE now() END
+		WHERE id = $1`, id, enabled)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to update webhook endpoint: %s", id)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "webhook endpoint not found: %s", id)
+	}
+
+	return nil
+}
+
+// DeleteWebhookEndpoint removes an endpoint together with its outbox and
+// delivery log. It returns sql.ErrNoRows if the endpoint does not exist.
+func (wr *WebhookRepo) DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "DeleteWebhookEndpoint")
+	defer cancel()
+
+	res, err := wr.data.Master().ExecContext(ctx, `DELETE FROM webhook_endpoint WHERE id = $1`, id)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to delete webhook endpoint: %s", id)
+	}
+
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return errs.WithMessagef(sql.ErrNoRows, "webhook endpoint not found: %s", id)
+	}
+
+	return nil
+}
+
+// RecordWebhookEndpointResult updates the endpoint's consecutive failure
+// counter and disables it once the counter reaches disableAfter. It reports
+// whether the endpoint is disabled afterwards.
+func (wr *WebhookRepo) RecordWebhookEndpointResult(ctx context.Context, id uuid.UUID, success bool, disableAfter int) (bool, error) {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "RecordWebhookEndpointResult")
+	defer cancel()
+
+	var enabled bool
+	var err error
+	if success {
+		err = wr.data.Master().QueryRowContext(ctx, `
+			UPDATE webhook_endpoint
+			SET consecutive_failures = 0
+			WHERE id = $1
+			RETURNING enabled`, id).Scan(&enabled)
+	} else {
+		err = wr.data.Master().QueryRowContext(ctx, `
+			UPDATE webhook_endpoint
+			SET consecutive_failures = consecutive_failures + 1,
+				enabled = enabled AND ($2 <= 0 OR consecutive_failures + 1 < $2),
+				disabled_at = CASE
+					WHEN enabled AND $2 > 0 AND consecutive_failures + 1 >= $2 THEN now()
+					ELSE disabled_at END
+			WHERE id = $1
+			RETURNING enabled`, id, disableAfter).Scan(&enabled)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, errs.WithMessagef(err, "failed to record result for webhook endpoint: %s", id)
+	}
+
+	return !enabled, nil
+}
+
+func (wr *WebhookRepo) AddWebhookMessage(ctx context.Context, m *models.WebhookMessage) error {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "AddWebhookMessage")
+	defer cancel()
+
+	_, err := wr.data.Master().ExecContext(ctx, `
+		INSERT INTO webhook_outbox (id, endpoint_id, url, secret, event, payload, attempts, status,
+			next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, m.ID, m.EndpointID, m.URL, m.Secret, m.Event,
+		m.Payload, m.Attempts, m.Status, m.NextAttemptAt, m.CreatedAt)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to add webhook message: %s", m.ID)
+	}
+
+	return nil
+}
+
+// ClaimDueWebhookMessages leases up to limit pending messages that are due at
+// now by pushing their next attempt to leaseUntil, so concurrent workers do
+// not deliver the same message twice. Messages of disabled endpoints are
+// left in the outbox.
+func (wr *WebhookRepo) ClaimDueWebhookMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookMessage, error) {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "ClaimDueWebhookMessages")
+	defer cancel()
+
+	query := `
+		UPDATE webhook_outbox
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT o.id
+			FROM webhook_outbox o
+			LEFT JOIN webhook_endpoint e ON e.id = o.endpoint_id
+			WHERE o.status = 'pending' AND o.next_attempt_at <= $1
+				AND (o.endpoint_id IS NULL OR e.enabled)
+			ORDER BY o.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF o SKIP LOCKED)
+		RETURNING ` + webhookMessageColumns
+
+	rows, err := wr.data.Master().QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var messages []*models.WebhookMessage
+	for rows.Next() {
+		m, err := scanWebhookMessage(rows)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		messages = append(messages, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return messages, nil
+}
+
+func (wr *WebhookRepo) UpdateWebhookMessage(ctx context.Context, m *models.WebhookMessage) error {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "UpdateWebhookMessage")
+	defer cancel()
+
+	_, err := wr.data.Master().ExecContext(ctx, `
+		UPDATE webhook_outbox
+		SET attempts = $2, status = $3, next_attempt_at = $4, last_error = $5
+		WHERE id = $1`, m.ID, m.Attempts, m.Status, m.NextAttemptAt, m.LastError)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to update webhook message: %s", m.ID)
+	}
+
+	return nil
+}
+
+func (wr *WebhookRepo) AddWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "AddWebhookDelivery")
+	defer cancel()
+
+	_, err := wr.data.Master().ExecContext(ctx, `
+		INSERT INTO webhook_delivery (id, message_id, endpoint_id, url, event, attempt, status_code, error,
+			duration_ms, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, d.ID, d.MessageID, d.EndpointID, d.URL, d.Event,
+		d.Attempt, d.StatusCode, d.Error, time.Duration(d.Duration).Milliseconds(), d.DeliveredAt)
+	if err != nil {
+		return errs.WithMessagef(err, "failed to add webhook delivery for message: %s", d.MessageID)
+	}
+
+	return nil
+}
+
+func (wr *WebhookRepo) GetWebhookDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, "GetWebhookDeliveries")
+	defer cancel()
+
+	query := `
+		SELECT id, message_id, endpoint_id, url, event, attempt, status_code, error, duration_ms, delivered_at
+		FROM webhook_delivery
+		WHERE endpoint_id = $1
+		ORDER BY delivered_at DESC
+		LIMIT $2`
+
+	rows, err := wr.data.Master().QueryContext(ctx, query, endpointID, limit)
+	if err != nil {
+		return nil, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			logger.Errf("failed to close rows: %s", err.Error())
+			return
+		}
+	}(rows)
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var endpoint uuid.NullUUID
+		var durationMs int64
+
+		err = rows.Scan(&d.ID, &d.MessageID, &endpoint, &d.URL, &d.Event, &d.Attempt, &d.StatusCode, &d.Error,
+			&durationMs, &d.DeliveredAt)
+		if err != nil {
+			return nil, errs.WithMessagef(err, "failed to scan row")
+		}
+		if endpoint.Valid {
+			d.EndpointID = &endpoint.UUID
+		}
+		d.Duration = models.Duration(time.Duration(durationMs) * time.Millisecond)
+		deliveries = append(deliveries, &d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithMessagef(err, "failed to iterate rows")
+	}
+
+	return deliveries, nil
+}
+
+// DeleteWebhookMessagesBefore deletes up to limit delivered or failed outbox
+// messages created before cutoff, together with their deliveries. Pending
+// messages are kept.
+func (wr *WebhookRepo) DeleteWebhookMessagesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return wr.exec(ctx, "DeleteWebhookMessagesBefore", `
+		DELETE FROM webhook_outbox
+		WHERE id IN (
+			SELECT id
+			FROM webhook_outbox
+			WHERE status <> 'pending' AND created_at < $1
+			LIMIT $2)`, cutoff, limit)
+}
+
+// DeleteWebhookDeliveriesBefore deletes up to limit delivery log entries
+// older than cutoff, also those of messages still being retried.
+func (wr *WebhookRepo) DeleteWebhookDeliveriesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return wr.exec(ctx, "DeleteWebhookDeliveriesBefore", `
+		DELETE FROM webhook_delivery
+		WHERE id IN (
+			SELECT id
+			FROM webhook_delivery
+			WHERE delivered_at < $1
+			LIMIT $2)`, cutoff, limit)
+}
+
+func (wr *WebhookRepo) exec(ctx context.Context, op, query string, args ...any) (int64, error) {
+	ctx, cancel := wr.timeouts.withTimeout(ctx, op)
+	defer cancel()
+
+	res, err := wr.data.Master().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errs.WithMessagef(err, "failed to exec query: %s", query)
+	}
+
+	ra, _ := res.RowsAffected()
+	return ra, nil
+}
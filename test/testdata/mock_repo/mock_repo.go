@@ -5,11 +5,14 @@
 package mock_repository
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
 	models "github.com/mashmorsik/quotation/pkg/models"
+	repository "github.com/mashmorsik/quotation/repository"
 )
 
 // MockRepository is a mock of Repository interface.
@@ -36,74 +39,936 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 }
 
 // AddQuotation mocks base method.
-func (m *MockRepository) AddQuotation(q *models.Quote) error {
+func (m *MockRepository) AddQuotation(ctx context.Context, q *models.Quote) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddQuotation", q)
+	ret := m.ctrl.Call(m, "AddQuotation", ctx, q)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddQuotation indicates an expected call of AddQuotation.
-func (mr *MockRepositoryMockRecorder) AddQuotation(q interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) AddQuotation(ctx, q interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddQuotation", reflect.TypeOf((*MockRepository)(nil).AddQuotation), q)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddQuotation", reflect.TypeOf((*MockRepository)(nil).AddQuotation), ctx, q)
 }
 
-// AddQuotePair mocks base method.
-func (m *MockRepository) AddQuotePair(from, to string) error {
+// AddQuotations mocks base method.
+func (m *MockRepository) AddQuotations(ctx context.Context, quotes []*models.Quote) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddQuotePair", from, to)
+	ret := m.ctrl.Call(m, "AddQuotations", ctx, quotes)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AddQuotations indicates an expected call of AddQuotations.
+func (mr *MockRepositoryMockRecorder) AddQuotations(ctx, quotes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddQuotations", reflect.TypeOf((*MockRepository)(nil).AddQuotations), ctx, quotes)
+}
+
+// AddQuotePair mocks base method.
+func (m *MockRepository) AddQuotePair(ctx context.Context, from, to string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddQuotePair", ctx, from, to)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // AddQuotePair indicates an expected call of AddQuotePair.
-func (mr *MockRepositoryMockRecorder) AddQuotePair(from, to interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) AddQuotePair(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddQuotePair", reflect.TypeOf((*MockRepository)(nil).AddQuotePair), ctx, from, to)
+}
+
+// DeleteQuotePair mocks base method.
+func (m *MockRepository) DeleteQuotePair(ctx context.Context, from, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteQuotePair", ctx, from, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteQuotePair indicates an expected call of DeleteQuotePair.
+func (mr *MockRepositoryMockRecorder) DeleteQuotePair(ctx, from, to interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddQuotePair", reflect.TypeOf((*MockRepository)(nil).AddQuotePair), from, to)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteQuotePair", reflect.TypeOf((*MockRepository)(nil).DeleteQuotePair), ctx, from, to)
 }
 
 // GetLastUpdated mocks base method.
-func (m *MockRepository) GetLastUpdated(from, to string) (*models.Quote, error) {
+func (m *MockRepository) GetLastUpdated(ctx context.Context, from, to string) (*models.Quote, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLastUpdated", from, to)
+	ret := m.ctrl.Call(m, "GetLastUpdated", ctx, from, to)
 	ret0, _ := ret[0].(*models.Quote)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLastUpdated indicates an expected call of GetLastUpdated.
-func (mr *MockRepositoryMockRecorder) GetLastUpdated(from, to interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetLastUpdated(ctx, from, to interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastUpdated", reflect.TypeOf((*MockRepository)(nil).GetLastUpdated), from, to)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastUpdated", reflect.TypeOf((*MockRepository)(nil).GetLastUpdated), ctx, from, to)
+}
+
+// GetLatestQuotes mocks base method.
+func (m *MockRepository) GetLatestQuotes(ctx context.Context, base string) ([]*models.Quote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestQuotes", ctx, base)
+	ret0, _ := ret[0].([]*models.Quote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestQuotes indicates an expected call of GetLatestQuotes.
+func (mr *MockRepositoryMockRecorder) GetLatestQuotes(ctx, base interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestQuotes", reflect.TypeOf((*MockRepository)(nil).GetLatestQuotes), ctx, base)
 }
 
 // GetQuotation mocks base method.
-func (m *MockRepository) GetQuotation(id uuid.UUID) (*models.Quote, error) {
+func (m *MockRepository) GetQuotation(ctx context.Context, id uuid.UUID) (*models.Quote, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetQuotation", id)
+	ret := m.ctrl.Call(m, "GetQuotation", ctx, id)
 	ret0, _ := ret[0].(*models.Quote)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetQuotation indicates an expected call of GetQuotation.
-func (mr *MockRepositoryMockRecorder) GetQuotation(id interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetQuotation(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotation", reflect.TypeOf((*MockRepository)(nil).GetQuotation), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotation", reflect.TypeOf((*MockRepository)(nil).GetQuotation), ctx, id)
+}
+
+// GetQuotationsSince mocks base method.
+func (m *MockRepository) GetQuotationsSince(ctx context.Context, from, to string, since time.Time) ([]*models.Quote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuotationsSince", ctx, from, to, since)
+	ret0, _ := ret[0].([]*models.Quote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuotationsSince indicates an expected call of GetQuotationsSince.
+func (mr *MockRepositoryMockRecorder) GetQuotationsSince(ctx, from, to, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotationsSince", reflect.TypeOf((*MockRepository)(nil).GetQuotationsSince), ctx, from, to, since)
 }
 
 // GetQuotePairs mocks base method.
-func (m *MockRepository) GetQuotePairs() ([][]string, error) {
+func (m *MockRepository) GetQuotePairs(ctx context.Context) ([][]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetQuotePairs")
+	ret := m.ctrl.Call(m, "GetQuotePairs", ctx)
 	ret0, _ := ret[0].([][]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetQuotePairs indicates an expected call of GetQuotePairs.
-func (mr *MockRepositoryMockRecorder) GetQuotePairs() *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetQuotePairs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotePairs", reflect.TypeOf((*MockRepository)(nil).GetQuotePairs), ctx)
+}
+
+// GetTrackedPair mocks base method.
+func (m *MockRepository) GetTrackedPair(ctx context.Context, from, to string) (*models.TrackedPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrackedPair", ctx, from, to)
+	ret0, _ := ret[0].(*models.TrackedPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrackedPair indicates an expected call of GetTrackedPair.
+func (mr *MockRepositoryMockRecorder) GetTrackedPair(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrackedPair", reflect.TypeOf((*MockRepository)(nil).GetTrackedPair), ctx, from, to)
+}
+
+// GetTrackedPairs mocks base method.
+func (m *MockRepository) GetTrackedPairs(ctx context.Context) ([]*models.TrackedPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrackedPairs", ctx)
+	ret0, _ := ret[0].([]*models.TrackedPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrackedPairs indicates an expected call of GetTrackedPairs.
+func (mr *MockRepositoryMockRecorder) GetTrackedPairs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrackedPairs", reflect.TypeOf((*MockRepository)(nil).GetTrackedPairs), ctx)
+}
+
+// LockQuotePair mocks base method.
+func (m *MockRepository) LockQuotePair(ctx context.Context, from, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockQuotePair", ctx, from, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockQuotePair indicates an expected call of LockQuotePair.
+func (mr *MockRepositoryMockRecorder) LockQuotePair(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockQuotePair", reflect.TypeOf((*MockRepository)(nil).LockQuotePair), ctx, from, to)
+}
+
+// RecordQuotePairFetch mocks base method.
+func (m *MockRepository) RecordQuotePairFetch(ctx context.Context, from, to string, at time.Time, fetchErr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordQuotePairFetch", ctx, from, to, at, fetchErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordQuotePairFetch indicates an expected call of RecordQuotePairFetch.
+func (mr *MockRepositoryMockRecorder) RecordQuotePairFetch(ctx, from, to, at, fetchErr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordQuotePairFetch", reflect.TypeOf((*MockRepository)(nil).RecordQuotePairFetch), ctx, from, to, at, fetchErr)
+}
+
+// RestoreQuotePair mocks base method.
+func (m *MockRepository) RestoreQuotePair(ctx context.Context, from, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreQuotePair", ctx, from, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreQuotePair indicates an expected call of RestoreQuotePair.
+func (mr *MockRepositoryMockRecorder) RestoreQuotePair(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreQuotePair", reflect.TypeOf((*MockRepository)(nil).RestoreQuotePair), ctx, from, to)
+}
+
+// SetQuotePairEnabled mocks base method.
+func (m *MockRepository) SetQuotePairEnabled(ctx context.Context, from, to string, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetQuotePairEnabled", ctx, from, to, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetQuotePairEnabled indicates an expected call of SetQuotePairEnabled.
+func (mr *MockRepositoryMockRecorder) SetQuotePairEnabled(ctx, from, to, enabled interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetQuotePairEnabled", reflect.TypeOf((*MockRepository)(nil).SetQuotePairEnabled), ctx, from, to, enabled)
+}
+
+// SetQuotePairSchedule mocks base method.
+func (m *MockRepository) SetQuotePairSchedule(ctx context.Context, from, to, schedule string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetQuotePairSchedule", ctx, from, to, schedule)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetQuotePairSchedule indicates an expected call of SetQuotePairSchedule.
+func (mr *MockRepositoryMockRecorder) SetQuotePairSchedule(ctx, from, to, schedule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetQuotePairSchedule", reflect.TypeOf((*MockRepository)(nil).SetQuotePairSchedule), ctx, from, to, schedule)
+}
+
+// WithTx mocks base method.
+func (m *MockRepository) WithTx(ctx context.Context, fn func(repository.Repository) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryMockRecorder) WithTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepository)(nil).WithTx), ctx, fn)
+}
+
+// MockAlertRepository is a mock of AlertRepository interface.
+type MockAlertRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAlertRepositoryMockRecorder
+}
+
+// MockAlertRepositoryMockRecorder is the mock recorder for MockAlertRepository.
+type MockAlertRepositoryMockRecorder struct {
+	mock *MockAlertRepository
+}
+
+// NewMockAlertRepository creates a new mock instance.
+func NewMockAlertRepository(ctrl *gomock.Controller) *MockAlertRepository {
+	mock := &MockAlertRepository{ctrl: ctrl}
+	mock.recorder = &MockAlertRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAlertRepository) EXPECT() *MockAlertRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddAlertFiring mocks base method.
+func (m *MockAlertRepository) AddAlertFiring(ctx context.Context, f *models.AlertFiring) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAlertFiring", ctx, f)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddAlertFiring indicates an expected call of AddAlertFiring.
+func (mr *MockAlertRepositoryMockRecorder) AddAlertFiring(ctx, f interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAlertFiring", reflect.TypeOf((*MockAlertRepository)(nil).AddAlertFiring), ctx, f)
+}
+
+// AddAlertRule mocks base method.
+func (m *MockAlertRepository) AddAlertRule(ctx context.Context, r *models.AlertRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAlertRule", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAlertRule indicates an expected call of AddAlertRule.
+func (mr *MockAlertRepositoryMockRecorder) AddAlertRule(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAlertRule", reflect.TypeOf((*MockAlertRepository)(nil).AddAlertRule), ctx, r)
+}
+
+// DeleteAlertRule mocks base method.
+func (m *MockAlertRepository) DeleteAlertRule(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAlertRule", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAlertRule indicates an expected call of DeleteAlertRule.
+func (mr *MockAlertRepositoryMockRecorder) DeleteAlertRule(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAlertRule", reflect.TypeOf((*MockAlertRepository)(nil).DeleteAlertRule), ctx, id)
+}
+
+// GetAlertFirings mocks base method.
+func (m *MockAlertRepository) GetAlertFirings(ctx context.Context, ruleID uuid.UUID) ([]*models.AlertFiring, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertFirings", ctx, ruleID)
+	ret0, _ := ret[0].([]*models.AlertFiring)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertFirings indicates an expected call of GetAlertFirings.
+func (mr *MockAlertRepositoryMockRecorder) GetAlertFirings(ctx, ruleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertFirings", reflect.TypeOf((*MockAlertRepository)(nil).GetAlertFirings), ctx, ruleID)
+}
+
+// GetAlertRule mocks base method.
+func (m *MockAlertRepository) GetAlertRule(ctx context.Context, id uuid.UUID) (*models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRule", ctx, id)
+	ret0, _ := ret[0].(*models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRule indicates an expected call of GetAlertRule.
+func (mr *MockAlertRepositoryMockRecorder) GetAlertRule(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRule", reflect.TypeOf((*MockAlertRepository)(nil).GetAlertRule), ctx, id)
+}
+
+// GetAlertRules mocks base method.
+func (m *MockAlertRepository) GetAlertRules(ctx context.Context) ([]*models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRules", ctx)
+	ret0, _ := ret[0].([]*models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRules indicates an expected call of GetAlertRules.
+func (mr *MockAlertRepositoryMockRecorder) GetAlertRules(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRules", reflect.TypeOf((*MockAlertRepository)(nil).GetAlertRules), ctx)
+}
+
+// GetAlertRulesForPair mocks base method.
+func (m *MockAlertRepository) GetAlertRulesForPair(ctx context.Context, from, to string) ([]*models.AlertRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAlertRulesForPair", ctx, from, to)
+	ret0, _ := ret[0].([]*models.AlertRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlertRulesForPair indicates an expected call of GetAlertRulesForPair.
+func (mr *MockAlertRepositoryMockRecorder) GetAlertRulesForPair(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlertRulesForPair", reflect.TypeOf((*MockAlertRepository)(nil).GetAlertRulesForPair), ctx, from, to)
+}
+
+// SetAlertRuleMatching mocks base method.
+func (m *MockAlertRepository) SetAlertRuleMatching(ctx context.Context, id uuid.UUID, matching bool) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAlertRuleMatching", ctx, id, matching)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAlertRuleMatching indicates an expected call of SetAlertRuleMatching.
+func (mr *MockAlertRepositoryMockRecorder) SetAlertRuleMatching(ctx, id, matching interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAlertRuleMatching", reflect.TypeOf((*MockAlertRepository)(nil).SetAlertRuleMatching), ctx, id, matching)
+}
+
+// UpdateAlertRule mocks base method.
+func (m *MockAlertRepository) UpdateAlertRule(ctx context.Context, r *models.AlertRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAlertRule", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAlertRule indicates an expected call of UpdateAlertRule.
+func (mr *MockAlertRepositoryMockRecorder) UpdateAlertRule(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAlertRule", reflect.TypeOf((*MockAlertRepository)(nil).UpdateAlertRule), ctx, r)
+}
+
+// MockWebhookRepository is a mock of WebhookRepository interface.
+type MockWebhookRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepositoryMockRecorder
+}
+
+// MockWebhookRepositoryMockRecorder is the mock recorder for MockWebhookRepository.
+type MockWebhookRepositoryMockRecorder struct {
+	mock *MockWebhookRepository
+}
+
+// NewMockWebhookRepository creates a new mock instance.
+func NewMockWebhookRepository(ctrl *gomock.Controller) *MockWebhookRepository {
+	mock := &MockWebhookRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepository) EXPECT() *MockWebhookRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddWebhookDelivery mocks base method.
+func (m *MockWebhookRepository) AddWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookDelivery", ctx, d)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookDelivery indicates an expected call of AddWebhookDelivery.
+func (mr *MockWebhookRepositoryMockRecorder) AddWebhookDelivery(ctx, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookDelivery", reflect.TypeOf((*MockWebhookRepository)(nil).AddWebhookDelivery), ctx, d)
+}
+
+// AddWebhookEndpoint mocks base method.
+func (m *MockWebhookRepository) AddWebhookEndpoint(ctx context.Context, e *models.WebhookEndpoint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookEndpoint", ctx, e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookEndpoint indicates an expected call of AddWebhookEndpoint.
+func (mr *MockWebhookRepositoryMockRecorder) AddWebhookEndpoint(ctx, e interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookEndpoint", reflect.TypeOf((*MockWebhookRepository)(nil).AddWebhookEndpoint), ctx, e)
+}
+
+// AddWebhookMessage mocks base method.
+func (m_2 *MockWebhookRepository) AddWebhookMessage(ctx context.Context, m *models.WebhookMessage) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "AddWebhookMessage", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookMessage indicates an expected call of AddWebhookMessage.
+func (mr *MockWebhookRepositoryMockRecorder) AddWebhookMessage(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookMessage", reflect.TypeOf((*MockWebhookRepository)(nil).AddWebhookMessage), ctx, m)
+}
+
+// ClaimDueWebhookMessages mocks base method.
+func (m *MockWebhookRepository) ClaimDueWebhookMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueWebhookMessages", ctx, now, leaseUntil, limit)
+	ret0, _ := ret[0].([]*models.WebhookMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueWebhookMessages indicates an expected call of ClaimDueWebhookMessages.
+func (mr *MockWebhookRepositoryMockRecorder) ClaimDueWebhookMessages(ctx, now, leaseUntil, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueWebhookMessages", reflect.TypeOf((*MockWebhookRepository)(nil).ClaimDueWebhookMessages), ctx, now, leaseUntil, limit)
+}
+
+// DeleteWebhookDeliveriesBefore mocks base method.
+func (m *MockWebhookRepository) DeleteWebhookDeliveriesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookDeliveriesBefore", ctx, cutoff, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebhookDeliveriesBefore indicates an expected call of DeleteWebhookDeliveriesBefore.
+func (mr *MockWebhookRepositoryMockRecorder) DeleteWebhookDeliveriesBefore(ctx, cutoff, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookDeliveriesBefore", reflect.TypeOf((*MockWebhookRepository)(nil).DeleteWebhookDeliveriesBefore), ctx, cutoff, limit)
+}
+
+// DeleteWebhookEndpoint mocks base method.
+func (m *MockWebhookRepository) DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookEndpoint", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookEndpoint indicates an expected call of DeleteWebhookEndpoint.
+func (mr *MockWebhookRepositoryMockRecorder) DeleteWebhookEndpoint(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookEndpoint", reflect.TypeOf((*MockWebhookRepository)(nil).DeleteWebhookEndpoint), ctx, id)
+}
+
+// DeleteWebhookMessagesBefore mocks base method.
+func (m *MockWebhookRepository) DeleteWebhookMessagesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookMessagesBefore", ctx, cutoff, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebhookMessagesBefore indicates an expected call of DeleteWebhookMessagesBefore.
+func (mr *MockWebhookRepositoryMockRecorder) DeleteWebhookMessagesBefore(ctx, cutoff, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookMessagesBefore", reflect.TypeOf((*MockWebhookRepository)(nil).DeleteWebhookMessagesBefore), ctx, cutoff, limit)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockWebhookRepository) GetWebhookDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", ctx, endpointID, limit)
+	ret0, _ := ret[0].([]*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) GetWebhookDeliveries(ctx, endpointID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).GetWebhookDeliveries), ctx, endpointID, limit)
+}
+
+// GetWebhookEndpoint mocks base method.
+func (m *MockWebhookRepository) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookEndpoint", ctx, id)
+	ret0, _ := ret[0].(*models.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookEndpoint indicates an expected call of GetWebhookEndpoint.
+func (mr *MockWebhookRepositoryMockRecorder) GetWebhookEndpoint(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookEndpoint", reflect.TypeOf((*MockWebhookRepository)(nil).GetWebhookEndpoint), ctx, id)
+}
+
+// GetWebhookEndpoints mocks base method.
+func (m *MockWebhookRepository) GetWebhookEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookEndpoints", ctx)
+	ret0, _ := ret[0].([]*models.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookEndpoints indicates an expected call of GetWebhookEndpoints.
+func (mr *MockWebhookRepositoryMockRecorder) GetWebhookEndpoints(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookEndpoints", reflect.TypeOf((*MockWebhookRepository)(nil).GetWebhookEndpoints), ctx)
+}
+
+// GetWebhookEndpointsForEvent mocks base method.
+func (m *MockWebhookRepository) GetWebhookEndpointsForEvent(ctx context.Context, event string) ([]*models.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookEndpointsForEvent", ctx, event)
+	ret0, _ := ret[0].([]*models.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookEndpointsForEvent indicates an expected call of GetWebhookEndpointsForEvent.
+func (mr *MockWebhookRepositoryMockRecorder) GetWebhookEndpointsForEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookEndpointsForEvent", reflect.TypeOf((*MockWebhookRepository)(nil).GetWebhookEndpointsForEvent), ctx, event)
+}
+
+// RecordWebhookEndpointResult mocks base method.
+func (m *MockWebhookRepository) RecordWebhookEndpointResult(ctx context.Context, id uuid.UUID, success bool, disableAfter int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordWebhookEndpointResult", ctx, id, success, disableAfter)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordWebhookEndpointResult indicates an expected call of RecordWebhookEndpointResult.
+func (mr *MockWebhookRepositoryMockRecorder) RecordWebhookEndpointResult(ctx, id, success, disableAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookEndpointResult", reflect.TypeOf((*MockWebhookRepository)(nil).RecordWebhookEndpointResult), ctx, id, success, disableAfter)
+}
+
+// SetWebhookEndpointEnabled mocks base method.
+func (m *MockWebhookRepository) SetWebhookEndpointEnabled(ctx context.Context, id uuid.UUID, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWebhookEndpointEnabled", ctx, id, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetWebhookEndpointEnabled indicates an expected call of SetWebhookEndpointEnabled.
+func (mr *MockWebhookRepositoryMockRecorder) SetWebhookEndpointEnabled(ctx, id, enabled interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWebhookEndpointEnabled", reflect.TypeOf((*MockWebhookRepository)(nil).SetWebhookEndpointEnabled), ctx, id, enabled)
+}
+
+// UpdateWebhookMessage mocks base method.
+func (m_2 *MockWebhookRepository) UpdateWebhookMessage(ctx context.Context, m *models.WebhookMessage) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "UpdateWebhookMessage", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookMessage indicates an expected call of UpdateWebhookMessage.
+func (mr *MockWebhookRepositoryMockRecorder) UpdateWebhookMessage(ctx, m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookMessage", reflect.TypeOf((*MockWebhookRepository)(nil).UpdateWebhookMessage), ctx, m)
+}
+
+// MockRetentionRepository is a mock of RetentionRepository interface.
+type MockRetentionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRetentionRepositoryMockRecorder
+}
+
+// MockRetentionRepositoryMockRecorder is the mock recorder for MockRetentionRepository.
+type MockRetentionRepositoryMockRecorder struct {
+	mock *MockRetentionRepository
+}
+
+// NewMockRetentionRepository creates a new mock instance.
+func NewMockRetentionRepository(ctrl *gomock.Controller) *MockRetentionRepository {
+	mock := &MockRetentionRepository{ctrl: ctrl}
+	mock.recorder = &MockRetentionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRetentionRepository) EXPECT() *MockRetentionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DailyWatermark mocks base method.
+func (m *MockRetentionRepository) DailyWatermark(ctx context.Context) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DailyWatermark", ctx)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DailyWatermark indicates an expected call of DailyWatermark.
+func (mr *MockRetentionRepositoryMockRecorder) DailyWatermark(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DailyWatermark", reflect.TypeOf((*MockRetentionRepository)(nil).DailyWatermark), ctx)
+}
+
+// DeleteDailyBefore mocks base method.
+func (m *MockRetentionRepository) DeleteDailyBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDailyBefore", ctx, cutoff, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteDailyBefore indicates an expected call of DeleteDailyBefore.
+func (mr *MockRetentionRepositoryMockRecorder) DeleteDailyBefore(ctx, cutoff, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDailyBefore", reflect.TypeOf((*MockRetentionRepository)(nil).DeleteDailyBefore), ctx, cutoff, limit)
+}
+
+// DeleteHourlyBefore mocks base method.
+func (m *MockRetentionRepository) DeleteHourlyBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteHourlyBefore", ctx, cutoff, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteHourlyBefore indicates an expected call of DeleteHourlyBefore.
+func (mr *MockRetentionRepositoryMockRecorder) DeleteHourlyBefore(ctx, cutoff, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteHourlyBefore", reflect.TypeOf((*MockRetentionRepository)(nil).DeleteHourlyBefore), ctx, cutoff, limit)
+}
+
+// DeleteRawBefore mocks base method.
+func (m *MockRetentionRepository) DeleteRawBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRawBefore", ctx, cutoff, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRawBefore indicates an expected call of DeleteRawBefore.
+func (mr *MockRetentionRepositoryMockRecorder) DeleteRawBefore(ctx, cutoff, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRawBefore", reflect.TypeOf((*MockRetentionRepository)(nil).DeleteRawBefore), ctx, cutoff, limit)
+}
+
+// HourlyWatermark mocks base method.
+func (m *MockRetentionRepository) HourlyWatermark(ctx context.Context) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HourlyWatermark", ctx)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HourlyWatermark indicates an expected call of HourlyWatermark.
+func (mr *MockRetentionRepositoryMockRecorder) HourlyWatermark(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HourlyWatermark", reflect.TypeOf((*MockRetentionRepository)(nil).HourlyWatermark), ctx)
+}
+
+// RollupDaily mocks base method.
+func (m *MockRetentionRepository) RollupDaily(ctx context.Context, from, to time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollupDaily", ctx, from, to)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RollupDaily indicates an expected call of RollupDaily.
+func (mr *MockRetentionRepositoryMockRecorder) RollupDaily(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollupDaily", reflect.TypeOf((*MockRetentionRepository)(nil).RollupDaily), ctx, from, to)
+}
+
+// RollupHourly mocks base method.
+func (m *MockRetentionRepository) RollupHourly(ctx context.Context, from, to time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollupHourly", ctx, from, to)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RollupHourly indicates an expected call of RollupHourly.
+func (mr *MockRetentionRepositoryMockRecorder) RollupHourly(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollupHourly", reflect.TypeOf((*MockRetentionRepository)(nil).RollupHourly), ctx, from, to)
+}
+
+// MockPartitionRepository is a mock of PartitionRepository interface.
+type MockPartitionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockPartitionRepositoryMockRecorder
+}
+
+// MockPartitionRepositoryMockRecorder is the mock recorder for MockPartitionRepository.
+type MockPartitionRepositoryMockRecorder struct {
+	mock *MockPartitionRepository
+}
+
+// NewMockPartitionRepository creates a new mock instance.
+func NewMockPartitionRepository(ctrl *gomock.Controller) *MockPartitionRepository {
+	mock := &MockPartitionRepository{ctrl: ctrl}
+	mock.recorder = &MockPartitionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPartitionRepository) EXPECT() *MockPartitionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateQuotationPartition mocks base method.
+func (m *MockPartitionRepository) CreateQuotationPartition(ctx context.Context, month time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateQuotationPartition", ctx, month)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateQuotationPartition indicates an expected call of CreateQuotationPartition.
+func (mr *MockPartitionRepositoryMockRecorder) CreateQuotationPartition(ctx, month interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateQuotationPartition", reflect.TypeOf((*MockPartitionRepository)(nil).CreateQuotationPartition), ctx, month)
+}
+
+// QuotationPartitions mocks base method.
+func (m *MockPartitionRepository) QuotationPartitions(ctx context.Context) ([]time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QuotationPartitions", ctx)
+	ret0, _ := ret[0].([]time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QuotationPartitions indicates an expected call of QuotationPartitions.
+func (mr *MockPartitionRepositoryMockRecorder) QuotationPartitions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QuotationPartitions", reflect.TypeOf((*MockPartitionRepository)(nil).QuotationPartitions), ctx)
+}
+
+// RemoveQuotationPartition mocks base method.
+func (m *MockPartitionRepository) RemoveQuotationPartition(ctx context.Context, month time.Time, detach bool) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveQuotationPartition", ctx, month, detach)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveQuotationPartition indicates an expected call of RemoveQuotationPartition.
+func (mr *MockPartitionRepositoryMockRecorder) RemoveQuotationPartition(ctx, month, detach interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveQuotationPartition", reflect.TypeOf((*MockPartitionRepository)(nil).RemoveQuotationPartition), ctx, month, detach)
+}
+
+// MockAPIKeyRepository is a mock of APIKeyRepository interface.
+type MockAPIKeyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyRepositoryMockRecorder
+}
+
+// MockAPIKeyRepositoryMockRecorder is the mock recorder for MockAPIKeyRepository.
+type MockAPIKeyRepositoryMockRecorder struct {
+	mock *MockAPIKeyRepository
+}
+
+// NewMockAPIKeyRepository creates a new mock instance.
+func NewMockAPIKeyRepository(ctrl *gomock.Controller) *MockAPIKeyRepository {
+	mock := &MockAPIKeyRepository{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyRepository) EXPECT() *MockAPIKeyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddAPIKey mocks base method.
+func (m *MockAPIKeyRepository) AddAPIKey(ctx context.Context, k *models.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAPIKey", ctx, k)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAPIKey indicates an expected call of AddAPIKey.
+func (mr *MockAPIKeyRepositoryMockRecorder) AddAPIKey(ctx, k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).AddAPIKey), ctx, k)
+}
+
+// GetAPIKeyByHash mocks base method.
+func (m *MockAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", ctx, hash)
+	ret0, _ := ret[0].(*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockAPIKeyRepositoryMockRecorder) GetAPIKeyByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockAPIKeyRepository)(nil).GetAPIKeyByHash), ctx, hash)
+}
+
+// GetAPIKeyUsage mocks base method.
+func (m *MockAPIKeyRepository) GetAPIKeyUsage(ctx context.Context, id uuid.UUID, since time.Time) ([]*models.APIKeyUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyUsage", ctx, id, since)
+	ret0, _ := ret[0].([]*models.APIKeyUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyUsage indicates an expected call of GetAPIKeyUsage.
+func (mr *MockAPIKeyRepositoryMockRecorder) GetAPIKeyUsage(ctx, id, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyUsage", reflect.TypeOf((*MockAPIKeyRepository)(nil).GetAPIKeyUsage), ctx, id, since)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockAPIKeyRepository) GetAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx)
+	ret0, _ := ret[0].([]*models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockAPIKeyRepositoryMockRecorder) GetAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockAPIKeyRepository)(nil).GetAPIKeys), ctx)
+}
+
+// IncrementAPIKeyUsage mocks base method.
+func (m *MockAPIKeyRepository) IncrementAPIKeyUsage(ctx context.Context, id uuid.UUID, day time.Time, quota int) (int64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementAPIKeyUsage", ctx, id, day, quota)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IncrementAPIKeyUsage indicates an expected call of IncrementAPIKeyUsage.
+func (mr *MockAPIKeyRepositoryMockRecorder) IncrementAPIKeyUsage(ctx, id, day, quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementAPIKeyUsage", reflect.TypeOf((*MockAPIKeyRepository)(nil).IncrementAPIKeyUsage), ctx, id, day, quota)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockAPIKeyRepositoryMockRecorder) RevokeAPIKey(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuotePairs", reflect.TypeOf((*MockRepository)(nil).GetQuotePairs))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).RevokeAPIKey), ctx, id)
 }